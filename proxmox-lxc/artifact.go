@@ -6,10 +6,14 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"log"
 	"os"
+	"strconv"
 )
 
 type Artifact struct {
+	outputMode    string
 	templatePath  string
+	templateID    int
+	node          string
 	proxmoxClient *proxmox.Client
 
 	// StateData should store data such as GeneratedData
@@ -25,15 +29,28 @@ func (*Artifact) BuilderId() string {
 }
 
 func (a *Artifact) Files() []string {
+	if a.outputMode == outputModeTemplate {
+		return nil
+	}
 	return []string{a.templatePath}
 }
 
 func (a *Artifact) Id() string {
-	return a.templatePath
+	if a.outputMode == outputModeVzdump {
+		return a.templatePath
+	}
+	return strconv.Itoa(a.templateID)
 }
 
 func (a *Artifact) String() string {
-	return fmt.Sprintf("A template was created: %s", a.templatePath)
+	switch a.outputMode {
+	case outputModeTemplate:
+		return fmt.Sprintf("A Proxmox template was created: %d on node %s", a.templateID, a.node)
+	case outputModeBoth:
+		return fmt.Sprintf("A Proxmox template was created: %d on node %s, and a vzdump template: %s", a.templateID, a.node, a.templatePath)
+	default:
+		return fmt.Sprintf("A template was created: %s", a.templatePath)
+	}
 }
 
 func (a *Artifact) State(name string) interface{} {
@@ -41,7 +58,20 @@ func (a *Artifact) State(name string) interface{} {
 }
 
 func (a *Artifact) Destroy() error {
-	log.Printf("Destroying template: %s", a.templatePath)
-	err := os.Remove(a.templatePath)
-	return err
+	if a.outputMode != outputModeVzdump {
+		log.Printf("Destroying Proxmox template: %d", a.templateID)
+		vmRef := proxmox.NewVmRef(a.templateID)
+		vmRef.SetNode(a.node)
+		vmRef.SetVmType("lxc")
+		if _, err := a.proxmoxClient.DeleteVm(vmRef); err != nil {
+			return err
+		}
+	}
+	if a.outputMode != outputModeTemplate {
+		log.Printf("Destroying template: %s", a.templatePath)
+		if err := os.Remove(a.templatePath); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,147 @@
+package proxmox_lxc
+
+import (
+	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// backupVolume is a single entry of the storage content listing.
+type backupVolume struct {
+	VolID  string `json:"volid"`
+	VMID   int    `json:"vmid"`
+	CTime  int64  `json:"ctime"`
+	Size   int64  `json:"size"`
+	Format string `json:"format"`
+
+	// Path is the location of the volume on the node, resolved separately
+	Path string `json:"-"`
+}
+
+// listBackupVolumes returns the backup volumes of the given container on a storage.
+func listBackupVolumes(session *proxmox.Session, node string, storage string, vmId int) ([]backupVolume, error) {
+	params := url.Values{}
+	params.Add("content", "backup")
+	params.Add("vmid", fmt.Sprint(vmId))
+
+	var volumes []backupVolume
+	err := getData(session, "/nodes/"+node+"/storage/"+storage+"/content", &params, &volumes)
+	return volumes, err
+}
+
+// rxArchiveLog matches the line vzdump logs when it starts writing the archive.
+var rxArchiveLog = regexp.MustCompile(`creating (?:vzdump )?archive '([^']+)'`)
+
+type taskLogLine struct {
+	N int    `json:"n"`
+	T string `json:"t"`
+}
+
+// readTaskLog returns all lines logged by the task so far.
+func readTaskLog(session *proxmox.Session, node string, upid string) ([]string, error) {
+	return readTaskLogFrom(session, node, upid, 0)
+}
+
+// readTaskLogFrom returns the lines logged by the task so far, starting with
+// line number start.
+func readTaskLogFrom(session *proxmox.Session, node string, upid string, start int) ([]string, error) {
+	const pageSize = 500
+	var lines []string
+	for {
+		params := url.Values{}
+		params.Add("start", strconv.Itoa(start+len(lines)))
+		params.Add("limit", strconv.Itoa(pageSize))
+
+		var page []taskLogLine
+		if err := getData(session, "/nodes/"+node+"/tasks/"+url.PathEscape(upid)+"/log", &params, &page); err != nil {
+			return lines, err
+		}
+		for _, line := range page {
+			// Line 0 is a placeholder such as "no content" for an empty log
+			if line.N > 0 {
+				lines = append(lines, line.T)
+			}
+		}
+		if len(page) < pageSize {
+			return lines, nil
+		}
+	}
+}
+
+// upidStartTime returns the start time encoded in a task UPID, in the
+// form UPID:node:pid:pstart:starttime:type:id:user:
+func upidStartTime(upid string) (int64, error) {
+	fields := strings.Split(upid, ":")
+	if len(fields) < 5 || fields[0] != "UPID" {
+		return 0, fmt.Errorf("invalid task id %q", upid)
+	}
+	return strconv.ParseInt(fields[4], 16, 64)
+}
+
+// locateBackup finds the archive written by the vzdump task upid. The archive
+// name is taken from the task log; if the log does not mention it, any backup
+// of the container with the given extension created since the task started is
+// accepted. More than one candidate is an error rather than a guess.
+func locateBackup(session *proxmox.Session, node string, storage string, vmId int, upid string, ext string) (*backupVolume, error) {
+	lines, err := readTaskLog(session, node, upid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vzdump task log: %s", err)
+	}
+	volumes, err := listBackupVolumes(session, node, storage, vmId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups on storage %s: %s", storage, err)
+	}
+
+	archive := ""
+	for _, line := range lines {
+		if m := rxArchiveLog.FindStringSubmatch(line); m != nil {
+			archive = path.Base(m[1])
+		}
+	}
+
+	var candidates []backupVolume
+	if archive != "" {
+		for _, volume := range volumes {
+			if path.Base(volume.VolID) == archive {
+				candidates = append(candidates, volume)
+			}
+		}
+	} else {
+		started, err := upidStartTime(upid)
+		if err != nil {
+			return nil, err
+		}
+		for _, volume := range volumes {
+			if volume.VMID == vmId && volume.CTime >= started && strings.HasSuffix(volume.VolID, ext) {
+				candidates = append(candidates, volume)
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("could not find backup on storage %s for LXC container %d", storage, vmId)
+	case 1:
+	default:
+		volids := make([]string, 0, len(candidates))
+		for _, volume := range candidates {
+			volids = append(volids, volume.VolID)
+		}
+		return nil, fmt.Errorf("found several backups on storage %s for LXC container %d: %s", storage, vmId, strings.Join(volids, ", "))
+	}
+
+	volume := candidates[0]
+	var attributes struct {
+		Path string `json:"path"`
+	}
+	err = getData(session, "/nodes/"+node+"/storage/"+storage+"/content/"+url.PathEscape(volume.VolID), nil, &attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path of %s: %s", volume.VolID, err)
+	}
+	volume.Path = attributes.Path
+	return &volume, nil
+}
@@ -0,0 +1,110 @@
+package proxmox_lxc
+
+import (
+	"encoding/json"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testUPID is a vzdump task started at 1600000000 (0x5F5E1000).
+const testUPID = "UPID:pve:00001234:00005678:5F5E1000:vzdump:100:root@pam:"
+
+// testBackupAPI serves the task log and the backup content of storage local
+// on node pve.
+func testBackupAPI(t *testing.T, log []string, volumes []backupVolume) *proxmox.Session {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+	mux.HandleFunc("/nodes/pve/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		lines := []taskLogLine{}
+		if r.URL.Query().Get("start") == "0" {
+			for i, line := range log {
+				lines = append(lines, taskLogLine{N: i + 1, T: line})
+			}
+		}
+		reply(w, lines)
+	})
+	mux.HandleFunc("/nodes/pve/storage/local/content", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, volumes)
+	})
+	mux.HandleFunc("/nodes/pve/storage/local/content/", func(w http.ResponseWriter, r *http.Request) {
+		volid := strings.TrimPrefix(r.URL.Path, "/nodes/pve/storage/local/content/")
+		reply(w, map[string]string{"path": "/var/lib/vz/dump/" + strings.TrimPrefix(volid, "local:backup/")})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	session, err := proxmox.NewSession(server.URL, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestLocateBackup(t *testing.T) {
+	older := backupVolume{VolID: "local:backup/vzdump-lxc-100-2020_09_13-10_00_00.tar.gz", VMID: 100, CTime: 1599990000}
+	written := backupVolume{VolID: "local:backup/vzdump-lxc-100-2020_09_13-12_26_40.tar.gz", VMID: 100, CTime: 1600000000}
+	parallel := backupVolume{VolID: "local:backup/vzdump-lxc-100-2020_09_13-12_26_50.tar.gz", VMID: 100, CTime: 1600000010}
+	otherFormat := backupVolume{VolID: "local:backup/vzdump-lxc-100-2020_09_13-12_26_45.tar.zst", VMID: 100, CTime: 1600000005}
+
+	for _, tc := range []struct {
+		name    string
+		log     []string
+		volumes []backupVolume
+		want    string
+		err     string
+	}{
+		{
+			name: "archive named in the task log",
+			log: []string{
+				"INFO: starting new backup job: vzdump 100 --storage local",
+				"INFO: creating vzdump archive '/var/lib/vz/dump/vzdump-lxc-100-2020_09_13-12_26_40.tar.gz'",
+				"INFO: Finished Backup of VM 100 (00:00:10)",
+			},
+			volumes: []backupVolume{older, written, parallel},
+			want:    written.VolID,
+		},
+		{
+			name:    "archive created since the task started",
+			log:     []string{"INFO: Finished Backup of VM 100 (00:00:10)"},
+			volumes: []backupVolume{older, written, otherFormat},
+			want:    written.VolID,
+		},
+		{
+			name:    "several archives created since the task started",
+			log:     []string{"INFO: Finished Backup of VM 100 (00:00:10)"},
+			volumes: []backupVolume{older, written, parallel},
+			err:     "found several backups on storage local for LXC container 100",
+		},
+		{
+			name:    "no archive created since the task started",
+			log:     []string{},
+			volumes: []backupVolume{older},
+			err:     "could not find backup on storage local for LXC container 100",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			session := testBackupAPI(t, tc.log, tc.volumes)
+			volume, err := locateBackup(session, "pve", "local", 100, testUPID, ".tar.gz")
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("locateBackup error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("locateBackup failed: %s", err)
+			}
+			if volume.VolID != tc.want {
+				t.Errorf("locateBackup = %s, want %s", volume.VolID, tc.want)
+			}
+			if want := "/var/lib/vz/dump/" + strings.TrimPrefix(tc.want, "local:backup/"); volume.Path != want {
+				t.Errorf("locateBackup path = %s, want %s", volume.Path, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package proxmox_lxc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// backupDownloadAttempts is how many times in a row a backup transfer may
+// break without progress before the download fails.
+const backupDownloadAttempts = 5
+
+// backupOpener opens the remote backup for reading from offset. It returns the
+// offset the reader actually starts at, which is 0 if the source can not seek.
+type backupOpener func(ctx context.Context, offset int64) (io.ReadCloser, int64, error)
+
+// downloadResumable downloads the backup volume to dstPath through open. The
+// data is written to a temporary file next to dstPath that is only renamed
+// once its size matches the content listing and its checksum matches the
+// remote file, so dstPath never holds a partial backup. A transfer that breaks
+// is resumed from the last offset. The runner for the checksum is only
+// connected once the transfer is complete, so it can not time out idling.
+func downloadResumable(ctx context.Context, ui packersdk.Ui, volume *backupVolume, dstPath string, open backupOpener, connect func() (nodeRunner, error)) error {
+	tmpPath := dstPath + ".part"
+	dstFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		dstFile.Close()
+		os.Remove(tmpPath)
+	}()
+
+	var offset int64
+	failures := 0
+	for {
+		reached, err := copyBackup(ctx, ui, volume, dstFile, offset, open)
+		if reached > offset {
+			failures = 0
+		}
+		offset = reached
+		if err == nil && (volume.Size == 0 || offset >= volume.Size) {
+			break
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		failures++
+		if failures >= backupDownloadAttempts {
+			return fmt.Errorf("transfer of %s failed after %d attempts: %s", volume.VolID, failures, err)
+		}
+		ui.Message(fmt.Sprintf("Transfer of %s interrupted at %d of %d bytes, resuming: %s", volume.VolID, offset, volume.Size, err))
+		select {
+		case <-time.After(time.Duration(failures) * 5 * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+
+	if volume.Size > 0 && offset != volume.Size {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", volume.VolID, volume.Size, offset)
+	}
+	runner, err := connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to node to checksum %s: %s", volume.VolID, err)
+	}
+	defer runner.Close()
+	if err := verifyBackupChecksum(ctx, ui, runner, volume, tmpPath); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, dstPath)
+}
+
+// copyBackup copies the backup from offset to the end into dstFile and
+// returns the offset it reached.
+func copyBackup(ctx context.Context, ui packersdk.Ui, volume *backupVolume, dstFile *os.File, offset int64, open backupOpener) (int64, error) {
+	src, start, err := open(ctx, offset)
+	if err != nil {
+		return offset, err
+	}
+	if start != offset {
+		log.Printf("Source of %s can not resume at %d, restarting at %d", volume.VolID, offset, start)
+		if err := dstFile.Truncate(start); err != nil {
+			src.Close()
+			return offset, err
+		}
+	}
+	if _, err := dstFile.Seek(start, io.SeekStart); err != nil {
+		src.Close()
+		return offset, err
+	}
+
+	body := ui.TrackProgress(volume.VolID, start, volume.Size, src)
+	defer body.Close()
+	n, err := io.Copy(dstFile, body)
+	return start + n, err
+}
+
+// verifyBackupChecksum compares the SHA-256 digest of the downloaded file with
+// the one of the backup on the node. The API has no way to checksum a volume,
+// so this runs sha256sum on the node.
+func verifyBackupChecksum(ctx context.Context, ui packersdk.Ui, runner nodeRunner, volume *backupVolume, file string) error {
+	ui.Say("Verifying checksum of " + volume.VolID + "...")
+	var stdout, stderr bytes.Buffer
+	if err := runner.Run(ctx, "sha256sum -- "+shellQuote(volume.Path), nil, &stdout, &stderr); err != nil {
+		return fmt.Errorf("failed to checksum %s on node: %s: %s", volume.VolID, err, strings.TrimSpace(stderr.String()))
+	}
+	fields := strings.Fields(stdout.String())
+	if len(fields) == 0 {
+		return fmt.Errorf("failed to checksum %s on node: no output from sha256sum", volume.VolID)
+	}
+	expected := fields[0]
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(digest.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("sha256 checksum mismatch for %s: expected %s, got %s", volume.VolID, expected, actual)
+	}
+	return nil
+}
@@ -2,7 +2,6 @@ package proxmox_lxc
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/Telmate/proxmox-api-go/proxmox"
@@ -11,6 +10,10 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"log"
+	"net"
+	"strings"
+	"time"
 )
 
 // The unique id for the builder
@@ -32,20 +35,23 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	if errs != nil {
 		return nil, warnings, errs
 	}
-	return nil, nil, nil
+	generatedData := []string{"VMID"}
+	return generatedData, warnings, nil
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
 	var err error
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: b.config.SkipCertValidation,
+	b.proxmoxClient, err = proxmox.NewClient(b.config.proxmoxURL.String(), nil, b.config.tlsConfig(), "", int(b.config.TaskTimeout.Seconds()))
+	if err != nil {
+		return nil, err
 	}
-	b.proxmoxClient, err = proxmox.NewClient(b.config.proxmoxURL.String(), nil, tlsConfig, "", 1200)
+
+	err = authenticate(b.proxmoxClient, &b.config)
 	if err != nil {
 		return nil, err
 	}
 
-	err = b.proxmoxClient.Login(b.config.Username, b.config.Password, "")
+	tasks, err := newTaskWaiter(&b.config, ui)
 	if err != nil {
 		return nil, err
 	}
@@ -54,14 +60,28 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
 	state.Put("proxmoxClient", b.proxmoxClient)
+	state.Put("taskWaiter", tasks)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
 	// Build the steps
-	var steps []multistep.Step
+	steps := []multistep.Step{
+		&stepSelectNode{},
+	}
+
+	// Only builds without key files or a password use a temporary key pair
+	if b.config.Comm.Type == "ssh" && b.config.Comm.SSHTemporaryKeyPairName != "" {
+		steps = append(steps, &communicator.StepSSHKeyGen{
+			CommConf:            &b.config.Comm,
+			SSHTemporaryKeyPair: b.config.Comm.SSHTemporaryKeyPair,
+		})
+	}
 
 	steps = append(steps,
+		&stepDownloadTemplate{},
+		&stepUploadTemplate{},
 		&stepStartContainer{},
+		&stepBootstrap{},
 		&commonsteps.StepHTTPServer{
 			HTTPDir:     b.config.HTTPDir,
 			HTTPPortMin: b.config.HTTPPortMin,
@@ -70,8 +90,11 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
-			Host:      commHost(b.config.ProvisionIP),
+			Host:      commHost(&b.config),
 			SSHConfig: b.config.Comm.SSHConfigFunc(),
+			CustomConnect: map[string]multistep.Step{
+				communicatorPct: &stepConnectPct{},
+			},
 		},
 		&commonsteps.StepProvision{},
 		&commonsteps.StepCleanupTempKeys{
@@ -93,8 +116,12 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("build was cancelled")
 	}
 
+	templateID, _ := state.Get("template_id").(int)
 	artifact := &Artifact{
+		outputMode:    b.config.OutputMode,
 		templatePath:  b.config.OutputPath,
+		templateID:    templateID,
+		node:          b.config.Node,
 		proxmoxClient: b.proxmoxClient,
 		StateData:     map[string]interface{}{"generated_data": state.Get("generated_data")},
 	}
@@ -104,34 +131,108 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 // Returns ssh_host or winrm_host (see communicator.Config.Host) config
 // parameter when set, otherwise gets the host IP from running VM
-func commHost(host string) func(state multistep.StateBag) (string, error) {
-	if host != "" {
+func commHost(c *Config) func(state multistep.StateBag) (string, error) {
+	if c.Comm.Type == communicatorPct {
+		return func(state multistep.StateBag) (string, error) {
+			return c.Node, nil
+		}
+	}
+	if c.ProvisionIP != "" {
 		return func(state multistep.StateBag) (string, error) {
-			return host, nil
+			return connectHost(c.ProvisionIP), nil
 		}
 	}
-	return getVMIP
+	return func(state multistep.StateBag) (string, error) {
+		return getVMIP(state, c)
+	}
 }
 
-// Reads the first non-loopback interface's IP address from the VM.
-// qemu-guest-agent package must be installed on the VM
-func getVMIP(state multistep.StateBag) (string, error) {
+// Polls the LXC interfaces endpoint until an address matching the
+// provision_interface and provision_cidr filters shows up, provision_ip_timeout
+// passes or the build is cancelled. IPv4 addresses are preferred.
+func getVMIP(state multistep.StateBag, c *Config) (string, error) {
+	if ip, ok := state.GetOk("provision_ip"); ok {
+		return connectHost(ip.(string)), nil
+	}
+
 	client := state.Get("proxmoxClient").(*proxmox.Client)
 	vmRef := state.Get("vmRef").(*proxmox.VmRef)
 
-	ifs, err := client.GetVmAgentNetworkInterfaces(vmRef)
-	if err != nil {
+	deadline := time.Now().Add(c.ProvisionIPTimeout)
+	for {
+		ip, err := findContainerIP(client, vmRef, c)
+		if err == nil {
+			log.Printf("Found container IP address: %s", ip)
+			state.Put("provision_ip", ip)
+			return connectHost(ip), nil
+		}
+		if time.Now().After(deadline) {
+			return "", err
+		}
+		log.Printf("Waiting for container IP address: %s", err)
+		// StepConnect only passes the state, where the runner records that
+		// the build was cancelled
+		for i := 0; i < 4; i++ {
+			if _, cancelled := state.GetOk(multistep.StateCancelled); cancelled {
+				return "", errors.New("build was cancelled while waiting for the container IP address")
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+}
+
+// connectHost returns the address in the form StepConnect needs, which joins
+// host and port without adding brackets to IPv6 addresses.
+func connectHost(ip string) string {
+	if strings.Contains(ip, ":") && !strings.HasPrefix(ip, "[") {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+func findContainerIP(client *proxmox.Client, vmRef *proxmox.VmRef, c *Config) (string, error) {
+	var data map[string]interface{}
+	url := fmt.Sprintf("/nodes/%s/lxc/%d/interfaces", vmRef.Node(), vmRef.VmId())
+	if err := client.GetJsonRetryable(url, &data, 3); err != nil {
 		return "", err
 	}
+	ifs, _ := data["data"].([]interface{})
 
-	for _, iface := range ifs {
-		for _, addr := range iface.IPAddresses {
-			if addr.IsLoopback() {
+	var ipv6 string
+	for _, rawIface := range ifs {
+		iface, ok := rawIface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := iface["name"].(string); c.ProvisionInterface != "" && name != c.ProvisionInterface {
+			continue
+		}
+		for _, key := range []string{"inet", "inet6"} {
+			raw, _ := iface[key].(string)
+			if raw == "" {
 				continue
 			}
-			return addr.String(), nil
+			ip, _, err := net.ParseCIDR(raw)
+			if err != nil {
+				ip = net.ParseIP(raw)
+			}
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			if c.provisionCIDR != nil && !c.provisionCIDR.Contains(ip) {
+				continue
+			}
+			if ip.To4() != nil {
+				return ip.String(), nil
+			}
+			if ipv6 == "" {
+				ipv6 = ip.String()
+			}
 		}
 	}
+	if ipv6 != "" {
+		return ipv6, nil
+	}
 
-	return "", fmt.Errorf("Found no IP addresses on VM")
+	return "", fmt.Errorf("Found no IP addresses on container")
 }
@@ -0,0 +1,176 @@
+package proxmox_lxc
+
+import (
+	"context"
+	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cloneSource looks up the container named by clone_vmid or clone_name.
+func cloneSource(client *proxmox.Client, c *Config) (*proxmox.VmRef, error) {
+	if c.CloneName == "" {
+		vmRef := proxmox.NewVmRef(c.CloneVMID)
+		if err := client.CheckVmRef(vmRef); err != nil {
+			return nil, err
+		}
+		if vmRef.GetVmType() != "lxc" {
+			return nil, fmt.Errorf("clone_vmid %d is not an LXC container", c.CloneVMID)
+		}
+		return vmRef, nil
+	}
+
+	vmRefs, err := client.GetVmRefsByName(c.CloneName)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*proxmox.VmRef
+	for _, vmRef := range vmRefs {
+		if vmRef.GetVmType() == "lxc" {
+			matches = append(matches, vmRef)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no LXC container named %s found", c.CloneName)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("%d LXC containers are named %s, use clone_vmid instead", len(matches), c.CloneName)
+	}
+}
+
+// cloneContainer clones the source container into vmRef on the build node.
+func cloneContainer(ctx context.Context, tasks *taskWaiter, c *Config, source *proxmox.VmRef, vmRef *proxmox.VmRef) error {
+	var body = url.Values{}
+	body.Add("newid", strconv.Itoa(vmRef.VmId()))
+	if source.Node() != c.Node {
+		body.Add("target", c.Node)
+	}
+	if !c.LinkedClone {
+		body.Add("full", "1")
+		if c.FSStorage != "" {
+			body.Add("storage", c.FSStorage)
+		}
+	}
+	if c.Pool != "" {
+		body.Add("pool", c.Pool)
+	}
+	_, err := tasks.post(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/clone", source.Node(), source.VmId()), body)
+	return err
+}
+
+// configureClone applies the configured resources, network adapters and
+// mount points to a clone. Options that are not set keep the value of the
+// source container.
+func configureClone(ctx context.Context, tasks *taskWaiter, c *Config, vmRef *proxmox.VmRef) error {
+	params := mountPointParams(c)
+	for key, value := range map[string]int{
+		"memory":   c.Memory,
+		"cores":    c.Cores,
+		"cpulimit": c.CPULimit,
+		"cpuunits": c.CPUUnits,
+	} {
+		if value > 0 {
+			params[key] = value
+		}
+	}
+	if c.Swap != nil {
+		params["swap"] = *c.Swap
+	}
+	if c.TTY != nil {
+		params["tty"] = *c.TTY
+	}
+	for key, value := range map[string]string{
+		"arch":         c.Arch,
+		"cmode":        c.ConsoleMode,
+		"ostype":       c.OSType,
+		"hostname":     c.Hostname,
+		"nameserver":   c.Nameserver,
+		"searchdomain": c.SearchDomain,
+		"timezone":     c.Timezone,
+		"tags":         strings.Join(c.Tags, ";"),
+		"description":  c.Description,
+	} {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	if c.Features.enabled() {
+		params["features"] = formatDevice(c.Features.device())
+	}
+	for i, nic := range c.NICs {
+		params["net"+strconv.Itoa(i)] = formatDevice(nic.device())
+	}
+	if err := tasks.setLxcConfig(ctx, vmRef, params); err != nil {
+		return err
+	}
+
+	// Proxmox can only grow volumes, a smaller filesystem_size is an error
+	if c.FSSize > 0 {
+		var body = url.Values{}
+		body.Add("disk", "rootfs")
+		body.Add("size", strconv.Itoa(c.FSSize)+"G")
+		if _, err := tasks.put(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/resize", vmRef.Node(), vmRef.VmId()), body); err != nil {
+			return fmt.Errorf("failed to resize rootfs of clone: %s", err)
+		}
+	}
+	return nil
+}
+
+// installCredentials sets the root password and authorizes the SSH public keys
+// inside a running clone, which unlike a new container can not be given them
+// through the API.
+func installCredentials(ctx context.Context, c *Config, vmId int, publicKeys string) error {
+	runner, err := newNodeRunner(c)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+
+	password := strings.NewReader("root:" + c.ProvisionPassword + "\n")
+	if err := runner.Run(ctx, pctExecCommand(vmId, "chpasswd"), password, nil, nil); err != nil {
+		return fmt.Errorf("failed to set root password: %s", err)
+	}
+	if publicKeys != "" {
+		keys := strings.NewReader(strings.TrimSpace(publicKeys) + "\n")
+		command := "mkdir -p /root/.ssh && chmod 700 /root/.ssh && cat >> /root/.ssh/authorized_keys && chmod 600 /root/.ssh/authorized_keys"
+		if err := runner.Run(ctx, pctExecCommand(vmId, command), keys, nil, nil); err != nil {
+			return fmt.Errorf("failed to authorize SSH public keys: %s", err)
+		}
+	}
+	return nil
+}
+
+// formatDevice formats a device option the way proxmox-api-go does for the
+// options it sets itself: true booleans, non-empty strings and positive
+// numbers, in key order.
+func formatDevice(device proxmox.QemuDevice) string {
+	keys := make([]string, 0, len(device))
+	for key := range device {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		switch value := device[key].(type) {
+		case bool:
+			if value {
+				params = append(params, key+"=1")
+			}
+		case int:
+			if value > 0 {
+				params = append(params, key+"="+strconv.Itoa(value))
+			}
+		case string:
+			if value != "" {
+				params = append(params, key+"="+value)
+			}
+		}
+	}
+	return strings.Join(params, ",")
+}
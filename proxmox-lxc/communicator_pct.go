@@ -0,0 +1,317 @@
+package proxmox_lxc
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const communicatorPct = "pct"
+
+// pctCommunicator implements packer.Communicator by running pct on the Proxmox
+// node, so the container itself does not need to be reachable from Packer.
+type pctCommunicator struct {
+	runner nodeRunner
+	vmId   int
+}
+
+// pctCommunicator implements packer.Communicator
+var _ packersdk.Communicator = &pctCommunicator{}
+
+func (p *pctCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	log.Printf("[DEBUG] pct exec %d: %s", p.vmId, cmd.Command)
+	go func() {
+		err := p.runner.Run(ctx, pctExecCommand(p.vmId, cmd.Command), cmd.Stdin, cmd.Stdout, cmd.Stderr)
+		cmd.SetExited(exitStatus(err))
+	}()
+	return nil
+}
+
+func (p *pctCommunicator) Upload(dst string, src io.Reader, fi *os.FileInfo) error {
+	ctx := context.TODO()
+	staging, cleanup, err := p.stagingPath(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to stage %s on node: %s", dst, err)
+	}
+	defer cleanup()
+
+	if err := p.runner.Run(ctx, "cat > "+shellQuote(staging), src, nil, nil); err != nil {
+		return fmt.Errorf("failed to stage %s on node: %s", dst, err)
+	}
+
+	push := fmt.Sprintf("pct push %d %s %s", p.vmId, shellQuote(staging), shellQuote(dst))
+	if fi != nil {
+		push += fmt.Sprintf(" --perms %o", (*fi).Mode().Perm())
+	}
+	if err := p.runner.Run(ctx, push, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to push %s into container: %s", dst, err)
+	}
+	return nil
+}
+
+func (p *pctCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	ctx := context.TODO()
+	// Like rsync, the source directory itself is only created at the
+	// destination when there is no trailing slash.
+	if !strings.HasSuffix(src, "/") {
+		dst = path.Join(dst, filepath.Base(src))
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(writer, src, exclude))
+	}()
+
+	command := fmt.Sprintf("mkdir -p %s && tar -C %s -xf -", shellQuote(dst), shellQuote(dst))
+	if err := p.runner.Run(ctx, pctExecCommand(p.vmId, command), reader, nil, nil); err != nil {
+		reader.CloseWithError(err)
+		return fmt.Errorf("failed to upload %s to %s: %s", src, dst, err)
+	}
+	return nil
+}
+
+func (p *pctCommunicator) Download(src string, dst io.Writer) error {
+	ctx := context.TODO()
+	staging, cleanup, err := p.stagingPath(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to stage %s on node: %s", src, err)
+	}
+	defer cleanup()
+
+	pull := fmt.Sprintf("pct pull %d %s %s", p.vmId, shellQuote(src), shellQuote(staging))
+	if err := p.runner.Run(ctx, pull, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to pull %s from container: %s", src, err)
+	}
+	if err := p.runner.Run(ctx, "cat "+shellQuote(staging), nil, dst, nil); err != nil {
+		return fmt.Errorf("failed to read %s from node: %s", src, err)
+	}
+	return nil
+}
+
+func (p *pctCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	ctx := context.TODO()
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := readTar(reader, dst, exclude)
+		reader.CloseWithError(err)
+		done <- err
+	}()
+
+	command := fmt.Sprintf("tar -C %s -cf - .", shellQuote(src))
+	err := p.runner.Run(ctx, pctExecCommand(p.vmId, command), nil, writer, nil)
+	writer.CloseWithError(err)
+	if extractErr := <-done; err == nil {
+		err = extractErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s to %s: %s", src, dst, err)
+	}
+	return nil
+}
+
+// stagingPath returns a file name on the node inside a new directory only
+// the SSH user can access, as provisioned files often hold secrets, and a
+// function that removes the directory again.
+func (p *pctCommunicator) stagingPath(ctx context.Context) (string, func(), error) {
+	var stdout, stderr bytes.Buffer
+	command := fmt.Sprintf("umask 077; mktemp -d /tmp/packer-pct-%d-XXXXXXXXXX", p.vmId)
+	if err := p.runner.Run(ctx, command, nil, &stdout, &stderr); err != nil {
+		return "", nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	dir := strings.TrimSpace(stdout.String())
+	if !strings.HasPrefix(dir, "/tmp/packer-pct-") {
+		return "", nil, fmt.Errorf("unexpected output from mktemp: %q", dir)
+	}
+	cleanup := func() {
+		p.runner.Run(ctx, "rm -rf "+shellQuote(dir), nil, nil, nil)
+	}
+	return dir + "/file", cleanup, nil
+}
+
+// exitStatus converts the error of a finished command into its exit status.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus()
+	}
+	var execErr *exec.ExitError
+	if errors.As(err, &execErr) {
+		return execErr.ExitCode()
+	}
+	log.Printf("[ERROR] pct command failed: %s", err)
+	return packersdk.CmdDisconnect
+}
+
+// excluded reports whether the slash separated relative path matches one of
+// the exclude patterns.
+func excluded(rel string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTar writes the contents of the local directory src as a tar stream.
+func writeTar(w io.Writer, src string, exclude []string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if excluded(rel, exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts a tar stream into the local directory dst.
+func readTar(r io.Reader, dst string, exclude []string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rel := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if rel == "." || excluded(rel, exclude) {
+			continue
+		}
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return fmt.Errorf("refusing to extract %s outside of %s", header.Name, dst)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		// A symlink extracted earlier must not redirect later entries
+		if err := checkInsideDir(root, filepath.Dir(target)); err != nil {
+			return fmt.Errorf("refusing to extract %s: %s", header.Name, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode).Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			// Replace rather than follow a symlink at the target
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			log.Printf("[DEBUG] Skipping %s of unsupported type %c", header.Name, header.Typeflag)
+		}
+	}
+}
+
+// checkInsideDir checks that dir, with symlinks resolved, is root or below it.
+// Directories that do not exist yet are checked through their deepest
+// existing ancestor, which is where they would be created.
+func checkInsideDir(root string, dir string) error {
+	existing := dir
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s resolves to %s outside of %s", dir, resolved, root)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package proxmox_lxc
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry is a file, directory or symlink of a test archive.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	target   string
+	content  string
+}
+
+func testTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.target,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+		}
+		if entry.typeflag == tar.TypeDir {
+			header.Mode = 0755
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadTar(t *testing.T) {
+	base, err := ioutil.TempDir("", "packer-pct-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	outside := filepath.Join(base, "outside")
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		entries []tarEntry
+		refused string
+	}{
+		{
+			name: "files, directories and symlinks inside",
+			entries: []tarEntry{
+				{name: "./dir", typeflag: tar.TypeDir},
+				{name: "./dir/file", typeflag: tar.TypeReg, content: "x"},
+				{name: "./link", typeflag: tar.TypeSymlink, target: "dir"},
+				{name: "./link/other", typeflag: tar.TypeReg, content: "y"},
+			},
+		},
+		{
+			name: "parent directory entry",
+			entries: []tarEntry{
+				{name: "../escaped", typeflag: tar.TypeReg, content: "x"},
+			},
+			refused: "../escaped",
+		},
+		{
+			name: "parent directory inside the path",
+			entries: []tarEntry{
+				{name: "./dir/../../escaped", typeflag: tar.TypeReg, content: "x"},
+			},
+			refused: "./dir/../../escaped",
+		},
+		{
+			name: "file through an absolute symlink",
+			entries: []tarEntry{
+				{name: "./link", typeflag: tar.TypeSymlink, target: outside},
+				{name: "./link/escaped", typeflag: tar.TypeReg, content: "x"},
+			},
+			refused: "./link/escaped",
+		},
+		{
+			name: "directory through a relative symlink",
+			entries: []tarEntry{
+				{name: "./link", typeflag: tar.TypeSymlink, target: "../outside"},
+				{name: "./link/dir/", typeflag: tar.TypeDir},
+				{name: "./link/dir/escaped", typeflag: tar.TypeReg, content: "x"},
+			},
+			refused: "./link/dir",
+		},
+		{
+			name: "file replacing a symlink",
+			entries: []tarEntry{
+				{name: "./link", typeflag: tar.TypeSymlink, target: filepath.Join(outside, "escaped")},
+				{name: "./link", typeflag: tar.TypeReg, content: "x"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, err := ioutil.TempDir(base, "dst")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = readTar(testTar(t, tc.entries), dst, nil)
+			if tc.refused == "" && err != nil {
+				t.Fatalf("readTar failed: %s", err)
+			}
+			if tc.refused != "" && (err == nil || !strings.Contains(err.Error(), "refusing to extract "+tc.refused)) {
+				t.Fatalf("readTar error = %v, want it to refuse %s", err, tc.refused)
+			}
+			if _, err := os.Lstat(filepath.Join(base, "escaped")); err == nil {
+				t.Fatal("readTar wrote outside of the destination")
+			}
+			if files, _ := ioutil.ReadDir(outside); len(files) > 0 {
+				t.Fatalf("readTar wrote %s outside of the destination", files[0].Name())
+			}
+		})
+	}
+}
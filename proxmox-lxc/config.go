@@ -1,25 +1,68 @@
-//go:generate mapstructure-to-hcl2 -type Config,nicConfig,diskConfig,vgaConfig,storageConfig
+//go:generate mapstructure-to-hcl2 -type Config,featuresConfig,nicConfig,mountPointConfig,deviceConfig,diskConfig,vgaConfig,storageConfig
 
 package proxmox_lxc
 
 import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
 	"github.com/mitchellh/mapstructure"
+	"io/ioutil"
 	"log"
+	"math/big"
+	"net"
 	"net/url"
 	"os"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 )
 
+const (
+	backupModeStop     = "stop"
+	backupModeSuspend  = "suspend"
+	backupModeSnapshot = "snapshot"
+
+	nodeAuto = "auto"
+
+	nodeCommandSSH   = "ssh"
+	nodeCommandLocal = "local"
+
+	outputModeTemplate = "template"
+	outputModeVzdump   = "vzdump"
+	outputModeBoth     = "both"
+)
+
+var (
+	rxHostname       = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+	rxTag            = regexp.MustCompile(`^[a-zA-Z0-9_+.-]+$`)
+	rxFilesystemType = regexp.MustCompile(`^[a-z0-9_.]+$`)
+	rxFileMode       = regexp.MustCompile(`^0?[0-7]{3}$`)
+	rxRawConfigKey   = regexp.MustCompile(`^lxc\.[a-z0-9_.]+$`)
+)
+
+// backupExtensions maps each backup_compression to the archive extension
+// vzdump writes.
+var backupExtensions = map[string]string{
+	"zstd": ".tar.zst",
+	"lzo":  ".tar.lzo",
+	"gzip": ".tar.gz",
+	"none": ".tar",
+}
+
 type Config struct {
 	common.PackerConfig    `mapstructure:",squash"`
 	commonsteps.HTTPConfig `mapstructure:",squash"`
@@ -30,31 +73,150 @@ type Config struct {
 	ProxmoxURLRaw      string `mapstructure:"proxmox_url"`
 	proxmoxURL         *url.URL
 	SkipCertValidation bool   `mapstructure:"insecure_skip_tls_verify"`
+	ProxmoxCAFile      string `mapstructure:"proxmox_ca_file"`
+	ProxmoxCAPEM       string `mapstructure:"proxmox_ca_pem"`
+	TLSFingerprint     string `mapstructure:"proxmox_tls_fingerprint"`
+	caPool             *x509.CertPool
 	Username           string `mapstructure:"username"`
 	Password           string `mapstructure:"password"`
+	TokenID            string `mapstructure:"token_id"`
+	TokenSecret        string `mapstructure:"token_secret"`
+	OTP                string `mapstructure:"otp"`
+	OTPSecret          string `mapstructure:"otp_secret"`
+	ticket             string
+	csrfToken          string
 	Node               string `mapstructure:"node"`
 	Pool               string `mapstructure:"pool"`
 
+	NodeCandidates        []string `mapstructure:"node_candidates"`
+	NodeLeastRecentlyUsed bool     `mapstructure:"node_least_recently_used"`
+	nodeAddress           string
+
 	Memory              int    `mapstructure:"memory"`
 	Cores               int    `mapstructure:"cores"`
 	Unprivileged        bool   `mapstructure:"unprivileged"`
 	TemplateFile        string `mapstructure:"template_file"`
+	TemplateAppliance   string `mapstructure:"template_appliance"`
+	TemplateURL         string `mapstructure:"template_url"`
+	TemplateChecksum    string `mapstructure:"template_checksum"`
+	TemplateLocalFile   string `mapstructure:"template_local_file"`
+	RemoveUploaded      bool   `mapstructure:"remove_uploaded_template"`
 	TemplateStoragePool string `mapstructure:"template_storage_pool"`
 	FSStorage           string `mapstructure:"filesystem_storage"`
 	FSSize              int    `mapstructure:"filesystem_size"`
 	VMID                int    `mapstructure:"vmid"`
+	VMIDRange           string `mapstructure:"vmid_range"`
+
+	Swap         *int           `mapstructure:"swap"`
+	CPULimit     int            `mapstructure:"cpulimit"`
+	CPUUnits     int            `mapstructure:"cpuunits"`
+	Arch         string         `mapstructure:"arch"`
+	OSType       string         `mapstructure:"ostype"`
+	Hostname     string         `mapstructure:"hostname"`
+	Nameserver   string         `mapstructure:"nameserver"`
+	SearchDomain string         `mapstructure:"searchdomain"`
+	Timezone     string         `mapstructure:"timezone"`
+	Tags         []string       `mapstructure:"tags"`
+	Description  string         `mapstructure:"description"`
+	ConsoleMode  string         `mapstructure:"console_mode"`
+	TTY          *int           `mapstructure:"tty"`
+	Features     featuresConfig `mapstructure:"features"`
+
+	CloneVMID   int    `mapstructure:"clone_vmid"`
+	CloneName   string `mapstructure:"clone_name"`
+	LinkedClone bool   `mapstructure:"linked_clone"`
+
+	NICs        []nicConfig        `mapstructure:"network_adapters"`
+	MountPoints []mountPointConfig `mapstructure:"mount_point"`
+	Devices     []deviceConfig     `mapstructure:"devices"`
+	RawConfig   []string           `mapstructure:"lxc_raw_config"`
+
+	NodeCommandMethod string   `mapstructure:"node_command_method"`
+	NodeSSHKnownHosts string   `mapstructure:"node_ssh_known_hosts_file"`
+	NodeSSHHostKey    string   `mapstructure:"node_ssh_host_key"`
+	BootstrapCommands []string `mapstructure:"bootstrap_commands"`
 
+	NodeSSHHost                  string `mapstructure:"node_ssh_host"`
+	NodeSSHUsername              string `mapstructure:"node_ssh_username"`
+	NodeSSHPort                  int    `mapstructure:"node_ssh_port"`
+	NodeSSHPassword              string `mapstructure:"node_ssh_password"`
+	NodeSSHPrivateKeyFile        string `mapstructure:"node_ssh_private_key_file"`
+	NodeSSHAgentAuth             bool   `mapstructure:"node_ssh_agent_auth"`
+	NodeSSHBastionHost           string `mapstructure:"node_ssh_bastion_host"`
+	NodeSSHBastionPort           int    `mapstructure:"node_ssh_bastion_port"`
+	NodeSSHBastionUsername       string `mapstructure:"node_ssh_bastion_username"`
+	NodeSSHBastionPassword       string `mapstructure:"node_ssh_bastion_password"`
+	NodeSSHBastionPrivateKeyFile string `mapstructure:"node_ssh_bastion_private_key_file"`
+	NodeSSHBastionAgentAuth      bool   `mapstructure:"node_ssh_bastion_agent_auth"`
+	NodeSSHBastionHostKey        string `mapstructure:"node_ssh_bastion_host_key"`
+
+	OutputMode              string `mapstructure:"output_mode"`
 	OutputPath              string `mapstructure:"output_path"`
+	BackupStorage           string `mapstructure:"backup_storage"`
+	BackupMode              string `mapstructure:"backup_mode"`
+	BackupCompression       string `mapstructure:"backup_compression"`
+	BackupZstdThreads       int    `mapstructure:"backup_zstd_threads"`
+	BackupBandwidthLimit    int    `mapstructure:"backup_bwlimit"`
 	ProvisionIP             string `mapstructure:"provision_ip"`
+	ProvisionInterface      string `mapstructure:"provision_interface"`
+	ProvisionCIDR           string `mapstructure:"provision_cidr"`
 	ProvisionMac            string `mapstructure:"provision_mac"`
 	ProvisionPort           int    `mapstructure:"provision_port"`
 	ProvisionPublicKeyPath  string `mapstructure:"provision_public_key_file"`
 	ProvisionPrivateKeyPath string `mapstructure:"provision_private_key_file"`
 	ProvisionPassword       string `mapstructure:"provision_password"`
 
+	TaskTimeout        time.Duration `mapstructure:"task_timeout"`
+	ProvisionIPTimeout time.Duration `mapstructure:"provision_ip_timeout"`
+	provisionCIDR      *net.IPNet
+	vmidMin            int
+	vmidMax            int
+
 	ctx interpolate.Context
 }
 
+type nicConfig struct {
+	Name       string  `mapstructure:"name"`
+	Bridge     string  `mapstructure:"bridge"`
+	VLANTag    int     `mapstructure:"vlan_tag"`
+	MTU        int     `mapstructure:"mtu"`
+	Rate       float64 `mapstructure:"rate"`
+	Firewall   bool    `mapstructure:"firewall"`
+	IP         string  `mapstructure:"ip"`
+	Gateway    string  `mapstructure:"gateway"`
+	IP6        string  `mapstructure:"ip6"`
+	Gateway6   string  `mapstructure:"gateway6"`
+	MACAddress string  `mapstructure:"mac_address"`
+}
+
+type featuresConfig struct {
+	Nesting bool     `mapstructure:"nesting"`
+	Keyctl  bool     `mapstructure:"keyctl"`
+	Fuse    bool     `mapstructure:"fuse"`
+	Mknod   bool     `mapstructure:"mknod"`
+	Mount   []string `mapstructure:"mount"`
+}
+
+type mountPointConfig struct {
+	Storage      string   `mapstructure:"storage"`
+	Size         int      `mapstructure:"size"`
+	HostPath     string   `mapstructure:"host_path"`
+	Path         string   `mapstructure:"path"`
+	ReadOnly     bool     `mapstructure:"read_only"`
+	Backup       bool     `mapstructure:"backup"`
+	Quota        bool     `mapstructure:"quota"`
+	ACL          bool     `mapstructure:"acl"`
+	MountOptions []string `mapstructure:"mount_options"`
+	Detach       bool     `mapstructure:"detach_before_vzdump"`
+}
+
+type deviceConfig struct {
+	Path string `mapstructure:"path"`
+	Mode string `mapstructure:"mode"`
+	UID  int    `mapstructure:"uid"`
+	GID  int    `mapstructure:"gid"`
+}
+
 func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	var md mapstructure.Metadata
 	err := config.Decode(c, &config.DecodeOpts{
@@ -64,6 +226,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"node_ssh_host",
 			},
 		},
 	}, raws...)
@@ -72,6 +235,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	var errs *packer.MultiError
+	var warnings []string
 	// Defaults
 	if c.ProxmoxURLRaw == "" {
 		c.ProxmoxURLRaw = os.Getenv("PROXMOX_URL")
@@ -82,15 +246,59 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	if c.Password == "" {
 		c.Password = os.Getenv("PROXMOX_PASSWORD")
 	}
+	if c.TokenID == "" {
+		c.TokenID = os.Getenv("PROXMOX_TOKEN_ID")
+	}
+	if c.TokenSecret == "" {
+		c.TokenSecret = os.Getenv("PROXMOX_TOKEN_SECRET")
+	}
+	if c.OTPSecret == "" {
+		c.OTPSecret = os.Getenv("PROXMOX_OTP_SECRET")
+	}
 
-	if c.Memory < 16 {
+	// Clones keep the resources of their source that are not set, so the
+	// defaults only apply to new containers
+	if c.Memory < 16 && (c.Memory != 0 || !c.cloning()) {
 		log.Printf("Memory %d is too small, using default: 512", c.Memory)
 		c.Memory = 512
 	}
-	if c.Cores < 1 {
+	if c.Cores < 1 && (c.Cores != 0 || !c.cloning()) {
 		log.Printf("Number of cores %d is too small, using default: 1", c.Cores)
 		c.Cores = 1
 	}
+	if !c.cloning() {
+		if c.Swap == nil {
+			swap := 512
+			c.Swap = &swap
+		}
+		if c.CPUUnits == 0 {
+			c.CPUUnits = 1024
+		}
+		if c.Arch == "" {
+			c.Arch = "amd64"
+		}
+		if c.ConsoleMode == "" {
+			c.ConsoleMode = "tty"
+		}
+		if c.TTY == nil {
+			tty := 2
+			c.TTY = &tty
+		}
+	}
+	// Keep the features unprivileged containers always had unless
+	// features are configured explicitly, clones keep those of the source
+	if c.Unprivileged && !c.Features.enabled() && !c.cloning() {
+		c.Features.Keyctl = true
+		c.Features.Nesting = true
+	}
+
+	if c.TaskTimeout <= 0 {
+		c.TaskTimeout = 20 * time.Minute
+	}
+
+	if c.ProvisionIPTimeout == 0 {
+		c.ProvisionIPTimeout = 5 * time.Minute
+	}
 
 	if c.ProvisionPort <= 0 {
 		c.ProvisionPort = 22
@@ -100,20 +308,121 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.ProvisionMac = "1e:eb:08:d1:e7:e2"
 	}
 
+	// A password without key files means password-only provisioning,
+	// otherwise a random root password is used next to the SSH key
 	if c.ProvisionPassword == "" {
-		c.ProvisionPassword = "provision"
+		c.ProvisionPassword = c.Comm.SSHPassword
+	}
+	passwordOnly := c.ProvisionPassword != "" && c.ProvisionPrivateKeyPath == "" && c.ProvisionPublicKeyPath == ""
+	if c.ProvisionPassword == "" {
+		if c.ProvisionPassword, err = randomPassword(24); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not generate provision_password: %s", err))
+		}
 	}
 
 	if c.TemplateStoragePool == "" {
 		c.TemplateStoragePool = "local"
 	}
 
+	// Clones keep the network adapters of their source unless set
+	if len(c.NICs) == 0 && !c.cloning() {
+		log.Printf("No network adapters specified, using default: vmbr0 with DHCP")
+		c.NICs = []nicConfig{{
+			Bridge:     "vmbr0",
+			IP:         "dhcp",
+			MACAddress: c.ProvisionMac,
+		}}
+	}
+	for i := range c.NICs {
+		if c.NICs[i].Name == "" {
+			c.NICs[i].Name = fmt.Sprintf("eth%d", i)
+		}
+	}
+
+	if c.NodeCommandMethod == "" {
+		c.NodeCommandMethod = nodeCommandSSH
+	}
+
+	if c.NodeSSHKnownHosts == "" {
+		c.NodeSSHKnownHosts = "~/.ssh/known_hosts"
+	}
+
+	// Without a separate SSH user the Linux account of a @pam API user is
+	// used, users of other realms have no Linux account
+	if c.NodeSSHUsername == "" {
+		user := c.Username
+		if user == "" {
+			user = strings.SplitN(c.TokenID, "!", 2)[0]
+		}
+		if strings.HasSuffix(user, "@pam") {
+			c.NodeSSHUsername = strings.TrimSuffix(user, "@pam")
+		}
+	}
+	if c.NodeSSHUsername == "" {
+		c.NodeSSHUsername = "root"
+	}
+	if c.NodeSSHPort == 0 {
+		c.NodeSSHPort = 22
+	}
+	if c.NodeSSHBastionPort == 0 {
+		c.NodeSSHBastionPort = 22
+	}
+	if c.NodeSSHBastionUsername == "" {
+		c.NodeSSHBastionUsername = c.NodeSSHUsername
+	}
+
+	if c.OutputMode == "" {
+		c.OutputMode = outputModeVzdump
+	}
+
+	if c.BackupStorage == "" {
+		c.BackupStorage = c.TemplateStoragePool
+	}
+
+	if c.BackupMode == "" {
+		c.BackupMode = backupModeStop
+	}
+
+	if c.BackupCompression == "" {
+		c.BackupCompression = "gzip"
+	}
+
 	// Required configurations that will display errors if not set
-	if c.Username == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("username must be specified"))
+	if c.TokenID != "" || c.TokenSecret != "" {
+		if c.TokenID == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("token_id must be specified together with token_secret"))
+		}
+		if c.TokenSecret == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("token_secret must be specified together with token_id"))
+		}
+		// A bare token name is relative to the configured user
+		if c.TokenID != "" && !strings.Contains(c.TokenID, "!") {
+			if c.Username == "" {
+				errs = packer.MultiErrorAppend(errs, errors.New("username must be specified when token_id is not in the form USER@REALM!TOKENID"))
+			} else {
+				c.TokenID = c.Username + "!" + c.TokenID
+			}
+		}
+	} else {
+		if c.Username == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("username must be specified"))
+		}
+		if c.Password == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("password must be specified"))
+		}
 	}
-	if c.Password == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("password must be specified"))
+	if c.OTP != "" || c.OTPSecret != "" {
+		if c.OTP != "" && c.OTPSecret != "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("only one of otp and otp_secret can be specified"))
+		}
+		if c.TokenID != "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("otp and otp_secret can not be used with an API token"))
+		}
+		if c.OTPSecret != "" {
+			if _, err := totp(c.OTPSecret, time.Now()); err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("otp_secret must be a base32 encoded TOTP secret: %s", err))
+			}
+		}
 	}
 	if c.ProxmoxURLRaw == "" {
 		errs = packer.MultiErrorAppend(errs, errors.New("proxmox_url must be specified"))
@@ -121,33 +430,376 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	if c.proxmoxURL, err = url.Parse(c.ProxmoxURLRaw); err != nil {
 		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not parse proxmox_url: %s", err))
 	}
+	if c.ProxmoxCAFile != "" || c.ProxmoxCAPEM != "" {
+		c.caPool = x509.NewCertPool()
+		if c.ProxmoxCAFile != "" {
+			pem, err := ioutil.ReadFile(c.ProxmoxCAFile)
+			if err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not read proxmox_ca_file: %s", err))
+			} else if !c.caPool.AppendCertsFromPEM(pem) {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("proxmox_ca_file %s contains no PEM encoded certificates", c.ProxmoxCAFile))
+			}
+		}
+		if c.ProxmoxCAPEM != "" && !c.caPool.AppendCertsFromPEM([]byte(c.ProxmoxCAPEM)) {
+			errs = packer.MultiErrorAppend(errs, errors.New("proxmox_ca_pem contains no PEM encoded certificates"))
+		}
+		if c.SkipCertValidation {
+			warnings = append(warnings, "proxmox_ca_file and proxmox_ca_pem are ignored when insecure_skip_tls_verify is set")
+		}
+	}
+	if c.TLSFingerprint != "" {
+		c.TLSFingerprint = strings.ToLower(strings.Replace(c.TLSFingerprint, ":", "", -1))
+		if _, err := hex.DecodeString(c.TLSFingerprint); err != nil || len(c.TLSFingerprint) != 64 {
+			errs = packer.MultiErrorAppend(errs, errors.New("proxmox_tls_fingerprint must be the SHA-256 fingerprint of the certificate, as printed by openssl x509 -fingerprint -sha256"))
+		}
+	}
+
+	if c.Node == "" && len(c.NodeCandidates) > 0 {
+		c.Node = nodeAuto
+	}
 	if c.Node == "" {
 		errs = packer.MultiErrorAppend(errs, errors.New("node must be specified"))
 	}
+	if c.Node != nodeAuto && (len(c.NodeCandidates) > 0 || c.NodeLeastRecentlyUsed) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("node_candidates and node_least_recently_used require node to be %s", nodeAuto))
+	}
 	if strings.ContainsAny(c.TemplateFile, " ") {
 		errs = packer.MultiErrorAppend(errs, errors.New("template_name must not contain spaces"))
 	}
-	if c.FSStorage == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("filesystem_storage must be specified"))
+	templateSources := 0
+	for _, source := range []string{c.TemplateAppliance, c.TemplateURL, c.TemplateLocalFile} {
+		if source != "" {
+			templateSources++
+		}
+	}
+	if templateSources > 1 {
+		errs = packer.MultiErrorAppend(errs, errors.New("only one of template_appliance, template_url and template_local_file can be specified"))
+	}
+	if c.TemplateLocalFile != "" {
+		if c.TemplateFile != "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("template_file is derived from template_local_file and must not be specified"))
+		}
+		if _, err := os.Stat(c.TemplateLocalFile); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("template_local_file is invalid: %s", err))
+		}
+		if templateExtension(c.TemplateLocalFile) == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("template_local_file must be a .tar, .tar.gz, .tgz, .tar.xz, .tar.bz2 or .tar.zst archive"))
+		}
+	} else if c.RemoveUploaded {
+		errs = packer.MultiErrorAppend(errs, errors.New("remove_uploaded_template requires template_local_file"))
+	}
+	if c.TemplateURL != "" {
+		u, err := url.Parse(c.TemplateURL)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not parse template_url: %s", err))
+		} else if c.TemplateFile == "" {
+			c.TemplateFile = path.Base(u.Path)
+		}
+	}
+	if c.TemplateChecksum != "" {
+		if c.TemplateURL == "" && c.TemplateLocalFile == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("template_checksum requires template_url or template_local_file"))
+		}
+		algorithm, value := splitChecksum(c.TemplateChecksum)
+		if !contains([]string{"md5", "sha1", "sha224", "sha256", "sha384", "sha512"}, algorithm) || value == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("template_checksum must be in the form <md5|sha1|sha224|sha256|sha384|sha512>:<value>"))
+		}
 	}
-	if c.FSSize <= 0 {
-		errs = packer.MultiErrorAppend(errs, errors.New("filesystem_size must be specified"))
+	if c.cloning() {
+		if c.CloneVMID != 0 && c.CloneName != "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("only one of clone_vmid and clone_name can be specified"))
+		}
+		if c.CloneVMID < 0 {
+			errs = packer.MultiErrorAppend(errs, errors.New("clone_vmid must not be negative"))
+		}
+		if c.TemplateFile != "" || templateSources > 0 {
+			errs = packer.MultiErrorAppend(errs, errors.New("template_file, template_appliance, template_url and template_local_file can not be used when cloning"))
+		}
+		if c.LinkedClone && c.FSStorage != "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("filesystem_storage can not be used with linked_clone, the clone uses the storage of its source"))
+		}
+		if c.FSSize < 0 {
+			errs = packer.MultiErrorAppend(errs, errors.New("filesystem_size must not be negative"))
+		}
+		if c.Unprivileged {
+			warnings = append(warnings, "unprivileged is ignored when cloning, the clone keeps the setting of its source")
+		}
+	} else {
+		if c.LinkedClone {
+			errs = packer.MultiErrorAppend(errs, errors.New("linked_clone requires clone_vmid or clone_name"))
+		}
+		if c.TemplateFile == "" && c.TemplateAppliance == "" && c.TemplateLocalFile == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("template_file must be specified"))
+		}
+		if c.FSStorage == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("filesystem_storage must be specified"))
+		}
+		if c.FSSize <= 0 {
+			errs = packer.MultiErrorAppend(errs, errors.New("filesystem_size must be specified"))
+		}
 	}
 
-	if c.ProvisionIP == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("provision_ip must be specified"))
+	for i, nic := range c.NICs {
+		if nic.Bridge == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].bridge must be specified", i))
+		}
+		if nic.VLANTag < 0 || nic.VLANTag > 4094 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].vlan_tag must be between 1 and 4094", i))
+		}
+		if nic.MTU < 0 || nic.Rate < 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d] mtu and rate must not be negative", i))
+		}
+		switch nic.IP {
+		case "", "dhcp", "manual":
+			if nic.Gateway != "" {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].gateway requires a static ip", i))
+			}
+		default:
+			if ip, _, err := net.ParseCIDR(nic.IP); err != nil || ip.To4() == nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].ip must be dhcp, manual or an IPv4 address in CIDR notation", i))
+			}
+		}
+		switch nic.IP6 {
+		case "", "dhcp", "auto", "manual":
+			if nic.Gateway6 != "" {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].gateway6 requires a static ip6", i))
+			}
+		default:
+			if ip, _, err := net.ParseCIDR(nic.IP6); err != nil || ip.To4() != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].ip6 must be dhcp, auto, manual or an IPv6 address in CIDR notation", i))
+			}
+		}
+		if nic.MACAddress != "" {
+			if _, err := net.ParseMAC(nic.MACAddress); err != nil {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].mac_address is invalid: %s", i, err))
+			}
+		}
+	}
+
+	if c.Swap != nil && *c.Swap < 0 {
+		errs = packer.MultiErrorAppend(errs, errors.New("swap must not be negative"))
+	}
+	if c.CPULimit < 0 || c.CPULimit > 8192 {
+		errs = packer.MultiErrorAppend(errs, errors.New("cpulimit must be between 0 and 8192"))
+	}
+	if c.CPUUnits < 0 || c.CPUUnits > 500000 {
+		errs = packer.MultiErrorAppend(errs, errors.New("cpuunits must be between 0 and 500000"))
+	}
+	if c.Arch != "" && !contains([]string{"amd64", "i386", "arm64", "armhf", "riscv32", "riscv64"}, c.Arch) {
+		errs = packer.MultiErrorAppend(errs, errors.New("arch must be one of amd64, i386, arm64, armhf, riscv32, riscv64"))
+	}
+	if c.OSType != "" && !contains([]string{"debian", "devuan", "ubuntu", "centos", "fedora", "opensuse", "archlinux", "alpine", "gentoo", "nixos", "unmanaged"}, c.OSType) {
+		errs = packer.MultiErrorAppend(errs, errors.New("ostype must be one of debian, devuan, ubuntu, centos, fedora, opensuse, archlinux, alpine, gentoo, nixos, unmanaged"))
+	}
+	if c.Hostname != "" && !rxHostname.MatchString(c.Hostname) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("hostname %s is not a valid DNS name", c.Hostname))
+	}
+	for _, nameserver := range strings.Fields(c.Nameserver) {
+		if net.ParseIP(nameserver) == nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("nameserver %s is not an IP address", nameserver))
+		}
+	}
+	for _, domain := range strings.Fields(c.SearchDomain) {
+		if !rxHostname.MatchString(domain) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("searchdomain %s is not a valid DNS name", domain))
+		}
+	}
+	if strings.ContainsAny(c.Timezone, " ,;") {
+		errs = packer.MultiErrorAppend(errs, errors.New("timezone must be host or a zone name such as Europe/Berlin"))
+	}
+	for _, tag := range c.Tags {
+		if !rxTag.MatchString(tag) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("tag %s may only contain letters, digits and _ - + .", tag))
+		}
+	}
+	if c.ConsoleMode != "" && !contains([]string{"tty", "console", "shell"}, c.ConsoleMode) {
+		errs = packer.MultiErrorAppend(errs, errors.New("console_mode must be one of tty, console, shell"))
+	}
+	if c.TTY != nil && (*c.TTY < 0 || *c.TTY > 6) {
+		errs = packer.MultiErrorAppend(errs, errors.New("tty must be between 0 and 6"))
+	}
+	if c.Features.Keyctl && !c.Unprivileged && !c.cloning() {
+		errs = packer.MultiErrorAppend(errs, errors.New("features.keyctl requires an unprivileged container"))
+	}
+	for _, mount := range c.Features.Mount {
+		if !rxFilesystemType.MatchString(mount) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("features.mount %s is not a filesystem type", mount))
+		}
+	}
+
+	if len(c.MountPoints) > 256 {
+		errs = packer.MultiErrorAppend(errs, errors.New("at most 256 mount_point blocks can be specified"))
+	}
+	for i, mp := range c.MountPoints {
+		if !path.IsAbs(mp.Path) || path.Clean(mp.Path) == "/" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d].path must be an absolute path other than /", i))
+		}
+		switch {
+		case mp.Storage != "" && mp.HostPath != "":
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d] can not have both storage and host_path", i))
+		case mp.Storage != "":
+			if mp.Size <= 0 {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d].size must be specified with storage", i))
+			}
+		case mp.HostPath != "":
+			if !path.IsAbs(mp.HostPath) {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d].host_path must be an absolute path", i))
+			}
+			if mp.Size != 0 || mp.Backup || mp.Quota {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d] size, backup and quota can not be used with host_path", i))
+			}
+		default:
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d] must have either storage or host_path", i))
+		}
+		for _, option := range mp.MountOptions {
+			if !contains([]string{"noatime", "nodev", "noexec", "nosuid", "lazytime"}, option) {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("mount_point[%d].mount_options must be noatime, nodev, noexec, nosuid or lazytime, not %s", i, option))
+			}
+		}
+		if mp.Detach && c.OutputMode == outputModeTemplate {
+			warnings = append(warnings, fmt.Sprintf("mount_point[%d].detach_before_vzdump is ignored when output_mode is %s", i, outputModeTemplate))
+		}
+		if mp.Detach && c.OutputMode == outputModeBoth {
+			warnings = append(warnings, fmt.Sprintf("mount_point[%d].detach_before_vzdump also removes the mount point from the template when output_mode is %s", i, outputModeBoth))
+		}
+	}
+
+	for i, dev := range c.Devices {
+		if !strings.HasPrefix(dev.Path, "/dev/") || strings.ContainsAny(dev.Path, ", ") {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("devices[%d].path must be a path below /dev/", i))
+		}
+		if dev.Mode != "" && !rxFileMode.MatchString(dev.Mode) {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("devices[%d].mode must be an octal access mode such as 0666", i))
+		}
+		if dev.UID < 0 || dev.GID < 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("devices[%d] uid and gid must not be negative", i))
+		}
+	}
+	for i, line := range c.RawConfig {
+		if key, value := splitRawConfig(line); !rxRawConfigKey.MatchString(key) || value == "" || strings.ContainsAny(line, "\r\n") {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("lxc_raw_config[%d] must be a single line in the form lxc.<key>: <value>", i))
+		}
+	}
+
+	if c.VMIDRange == "" {
+		c.VMIDRange = "100-999999999"
+	}
+	if _, err := fmt.Sscanf(c.VMIDRange, "%d-%d", &c.vmidMin, &c.vmidMax); err != nil || c.vmidMin < 100 || c.vmidMax > 999999999 || c.vmidMin > c.vmidMax {
+		errs = packer.MultiErrorAppend(errs, errors.New("vmid_range must be in the form <first>-<last> between 100 and 999999999"))
+	}
+	if c.VMID < 0 {
+		errs = packer.MultiErrorAppend(errs, errors.New("vmid must not be negative"))
+	}
+
+	if c.ProvisionCIDR != "" {
+		if _, c.provisionCIDR, err = net.ParseCIDR(c.ProvisionCIDR); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not parse provision_cidr: %s", err))
+		}
+	}
+
+	// Without key files or a password a temporary key pair is generated for the build
+	if (c.ProvisionPublicKeyPath == "") != (c.ProvisionPrivateKeyPath == "") {
+		errs = packer.MultiErrorAppend(errs, errors.New("provision_public_key_file and provision_private_key_file must be specified together"))
+	}
+	if c.ProvisionPrivateKeyPath == "" && !passwordOnly && c.Comm.Type != communicatorPct {
+		if c.Comm.SSHTemporaryKeyPairName == "" {
+			c.Comm.SSHTemporaryKeyPairName = fmt.Sprintf("packer_%s", uuid.TimeOrderedUUID())
+		}
+		if c.Comm.SSHTemporaryKeyPairType == "" {
+			c.Comm.SSHTemporaryKeyPairType = "ed25519"
+		}
+		c.Comm.SSHClearAuthorizedKeys = true
+	}
+
+	nodeSSHAuth := c.hasNodeSSHAuth()
+	switch c.NodeCommandMethod {
+	case nodeCommandSSH:
+		if c.runsNodeCommands() && !nodeSSHAuth {
+			errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_private_key_file, node_ssh_agent_auth, node_ssh_password or the password of a @pam user must be specified to run commands on the node over SSH"))
+		}
+	case nodeCommandLocal:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("node_command_method must be one of %s, %s", nodeCommandSSH, nodeCommandLocal))
 	}
 
-	if c.ProvisionPublicKeyPath == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("provision_public_key_file must be specified"))
+	if c.NodeSSHHost != "" {
+		ctx := c.ctx
+		ctx.Data = &nodeSSHHostData{Node: "node"}
+		if _, err := interpolate.Render(c.NodeSSHHost, &ctx); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not parse node_ssh_host: %s", err))
+		}
+	}
+	if c.NodeSSHHostKey != "" && !strings.HasPrefix(c.NodeSSHHostKey, "SHA256:") {
+		errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_host_key must be a SHA256 fingerprint as printed by ssh-keygen -l, such as SHA256:..."))
+	}
+	if c.NodeSSHBastionHostKey != "" && !strings.HasPrefix(c.NodeSSHBastionHostKey, "SHA256:") {
+		errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_bastion_host_key must be a SHA256 fingerprint as printed by ssh-keygen -l, such as SHA256:..."))
+	}
+	if c.NodeSSHPort < 0 || c.NodeSSHPort > 65535 || c.NodeSSHBastionPort < 0 || c.NodeSSHBastionPort > 65535 {
+		errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_port and node_ssh_bastion_port must be between 1 and 65535"))
+	}
+	for _, file := range []string{c.NodeSSHPrivateKeyFile, c.NodeSSHBastionPrivateKeyFile} {
+		if file == "" {
+			continue
+		}
+		if path, err := pathing.ExpandUser(file); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Could not expand %s: %s", file, err))
+		} else if _, err := os.Stat(path); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("SSH private key file is invalid: %s", err))
+		}
+	}
+	if c.NodeSSHBastionHost == "" && (c.NodeSSHBastionPassword != "" || c.NodeSSHBastionPrivateKeyFile != "" || c.NodeSSHBastionAgentAuth || c.NodeSSHBastionHostKey != "") {
+		errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_bastion_* settings require node_ssh_bastion_host"))
+	}
+	if c.NodeSSHBastionHost != "" && c.NodeSSHBastionPassword == "" && c.NodeSSHBastionPrivateKeyFile == "" && !c.NodeSSHBastionAgentAuth {
+		errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_bastion_password, node_ssh_bastion_private_key_file or node_ssh_bastion_agent_auth must be specified"))
 	}
 
-	if c.ProvisionPrivateKeyPath == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("provision_private_key_file must be specified"))
+	switch c.OutputMode {
+	case outputModeTemplate:
+	case outputModeVzdump, outputModeBoth:
+		if c.OutputPath == "" {
+			errs = packer.MultiErrorAppend(errs, errors.New("output_path must be specified"))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("output_mode must be one of %s, %s, %s", outputModeTemplate, outputModeVzdump, outputModeBoth))
 	}
 
-	if c.OutputPath == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("output_path must be specified"))
+	switch c.BackupMode {
+	case backupModeStop, backupModeSuspend, backupModeSnapshot:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("backup_mode must be one of %s, %s, %s", backupModeStop, backupModeSuspend, backupModeSnapshot))
+	}
+	if c.BackupMode != backupModeStop && c.OutputMode != outputModeTemplate && c.detachesMountPoints() {
+		warnings = append(warnings, fmt.Sprintf("backup_mode %s behaves like %s, mount points can only be detached from a stopped container", c.BackupMode, backupModeStop))
+	}
+	if ext, ok := backupExtensions[c.BackupCompression]; !ok {
+		errs = packer.MultiErrorAppend(errs, errors.New("backup_compression must be one of zstd, lzo, gzip, none"))
+	} else if c.OutputPath != "" && !strings.HasSuffix(c.OutputPath, ext) {
+		outputPath := c.OutputPath
+		for _, other := range backupExtensions {
+			if strings.HasSuffix(outputPath, other) {
+				outputPath = strings.TrimSuffix(outputPath, other)
+				break
+			}
+		}
+		outputPath += ext
+		warnings = append(warnings, fmt.Sprintf("output_path %s does not match backup_compression %s, writing to %s instead", c.OutputPath, c.BackupCompression, outputPath))
+		c.OutputPath = outputPath
+	}
+	if c.BackupZstdThreads < 0 {
+		errs = packer.MultiErrorAppend(errs, errors.New("backup_zstd_threads must not be negative"))
+	} else if c.BackupZstdThreads > 0 && c.BackupCompression != "zstd" {
+		warnings = append(warnings, "backup_zstd_threads is ignored unless backup_compression is zstd")
+	}
+	if c.BackupBandwidthLimit < 0 {
+		errs = packer.MultiErrorAppend(errs, errors.New("backup_bwlimit must not be negative"))
+	}
+
+	// The backup is downloaded over SFTP, the storage API has no download
+	// endpoint
+	if c.OutputMode != outputModeTemplate && !nodeSSHAuth {
+		errs = packer.MultiErrorAppend(errs, errors.New("node_ssh_private_key_file, node_ssh_agent_auth, node_ssh_password or the password of a @pam user must be specified to download the backup"))
 	}
 
 	// Set internal values
@@ -156,19 +808,125 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	c.Comm.SSHHost = c.ProvisionIP
 	c.Comm.SSHPort = c.ProvisionPort
 	c.Comm.SSHUsername = "root"
-	
-	//c.Comm.SSHPassword = c.ProvisionPassword
+	c.Comm.SSHPassword = c.ProvisionPassword
 
+	// The SDK does not know the pct communicator, it has nothing to prepare
+	commType := c.Comm.Type
+	if commType == communicatorPct {
+		c.Comm.Type = "none"
+	}
 	errs = packer.MultiErrorAppend(errs, c.Comm.Prepare(&c.ctx)...)
+	c.Comm.Type = commType
 	errs = packer.MultiErrorAppend(errs, c.BootConfig.Prepare(&c.ctx)...)
 	errs = packer.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
 
 	if errs != nil && len(errs.Errors) > 0 {
-		return nil, errs
+		return warnings, errs
 	}
 
-	packer.LogSecretFilter.Set(c.Password)
-	return nil, nil
+	packer.LogSecretFilter.Set(c.Password, c.TokenSecret, c.OTPSecret, c.ProvisionPassword, c.NodeSSHPassword, c.NodeSSHBastionPassword)
+	return warnings, nil
+}
+
+// enabled reports whether any feature is set.
+func (f featuresConfig) enabled() bool {
+	return f.Nesting || f.Keyctl || f.Fuse || f.Mknod || len(f.Mount) > 0
+}
+
+// device converts the features into the features option of the LXC config.
+func (f featuresConfig) device() proxmox.QemuDevice {
+	return proxmox.QemuDevice{
+		"nesting": f.Nesting,
+		"keyctl":  f.Keyctl,
+		"fuse":    f.Fuse,
+		"mknod":   f.Mknod,
+		"mount":   strings.Join(f.Mount, ";"),
+	}
+}
+
+// cloning reports whether the container is cloned instead of created from an
+// OS template.
+func (c *Config) cloning() bool {
+	return c.CloneVMID != 0 || c.CloneName != ""
+}
+
+// detachesMountPoints reports whether any mount point is detached before the
+// backup.
+func (c *Config) detachesMountPoints() bool {
+	for _, mp := range c.MountPoints {
+		if mp.Detach {
+			return true
+		}
+	}
+	return false
+}
+
+// runsNodeCommands reports whether the build runs commands on the node.
+func (c *Config) runsNodeCommands() bool {
+	return len(c.BootstrapCommands) > 0 || c.Comm.Type == communicatorPct || len(c.RawConfig) > 0 || (c.cloning() && c.Comm.Type == "ssh")
+}
+
+// hasNodeSSHAuth reports whether credentials for SSH connections to the node
+// are configured.
+func (c *Config) hasNodeSSHAuth() bool {
+	return c.NodeSSHPrivateKeyFile != "" || c.NodeSSHAgentAuth || c.nodeSSHPassword() != ""
+}
+
+// nodeSSHPassword returns the password for SSH connections to the node. The
+// API password is only offered to the node when no key is configured and it
+// is the password of the Linux account, that is of a @pam user.
+func (c *Config) nodeSSHPassword() string {
+	if c.NodeSSHPassword != "" {
+		return c.NodeSSHPassword
+	}
+	if c.NodeSSHPrivateKeyFile == "" && !c.NodeSSHAgentAuth && c.TokenID == "" && strings.HasSuffix(c.Username, "@pam") {
+		return c.Password
+	}
+	return ""
+}
+
+// splitRawConfig splits a raw config line in the form key: value or
+// key = value.
+func splitRawConfig(line string) (string, string) {
+	i := strings.IndexAny(line, ":=")
+	if i < 0 {
+		return strings.TrimSpace(line), ""
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+}
+
+// splitChecksum splits a checksum in the form algorithm:value.
+func splitChecksum(checksum string) (string, string) {
+	parts := strings.SplitN(checksum, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.ToLower(parts[0]), parts[1]
+}
+
+// templateExtension returns the archive extension of a container template
+// file name, or an empty string if it is not a supported archive.
+func templateExtension(name string) string {
+	for _, ext := range []string{".tar.gz", ".tar.xz", ".tar.zst", ".tar.bz2", ".tgz", ".tar"} {
+		if strings.HasSuffix(name, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// randomPassword returns a random alphanumeric password of the given length.
+func randomPassword(length int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = alphabet[n.Int64()]
+	}
+	return string(password), nil
 }
 
 func contains(haystack []string, needle string) bool {
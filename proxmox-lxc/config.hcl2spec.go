@@ -1,4 +1,5 @@
-// Code generated by "mapstructure-to-hcl2 -type Config,nicConfig,diskConfig,vgaConfig,storageConfig"; DO NOT EDIT.
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
 package proxmox_lxc
 
 import (
@@ -9,89 +10,157 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerDebug               *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	HTTPDir                   *string           `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
-	HTTPPortMin               *int              `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
-	HTTPPortMax               *int              `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
-	HTTPAddress               *string           `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
-	BootGroupInterval         *string           `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait                  *string           `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand               []string          `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	Type                      *string           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int              `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string           `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string           `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string           `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string           `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHCiphers                []string          `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool             `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string          `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string           `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string           `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool             `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string           `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string           `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool             `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool             `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int              `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string           `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int              `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool             `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string           `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string           `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool             `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string           `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string           `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string           `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string           `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int              `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string           `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string           `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string           `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string           `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string          `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string          `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte            `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte            `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string           `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string           `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string           `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool             `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int              `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string           `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	BootKeyInterval           *string           `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
-	ProxmoxURLRaw             *string           `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
-	SkipCertValidation        *bool             `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
-	Username                  *string           `mapstructure:"username" cty:"username" hcl:"username"`
-	Password                  *string           `mapstructure:"password" cty:"password" hcl:"password"`
-	Node                      *string           `mapstructure:"node" cty:"node" hcl:"node"`
-	Pool                      *string           `mapstructure:"pool" cty:"pool" hcl:"pool"`
-	Memory                    *int              `mapstructure:"memory" cty:"memory" hcl:"memory"`
-	Cores                     *int              `mapstructure:"cores" cty:"cores" hcl:"cores"`
-	Unprivileged              *bool             `mapstructure:"unprivileged" cty:"unprivileged" hcl:"unprivileged"`
-	TemplateFile              *string           `mapstructure:"template_file" cty:"template_file" hcl:"template_file"`
-	TemplateStoragePool       *string           `mapstructure:"template_storage_pool" cty:"template_storage_pool" hcl:"template_storage_pool"`
-	FSStorage                 *string           `mapstructure:"filesystem_storage" cty:"filesystem_storage" hcl:"filesystem_storage"`
-	FSSize                    *int              `mapstructure:"filesystem_size" cty:"filesystem_size" hcl:"filesystem_size"`
-	VMID                      *int              `mapstructure:"vmid" cty:"vmid" hcl:"vmid"`
-	OutputPath                *string           `mapstructure:"output_path" cty:"output_path" hcl:"output_path"`
-	ProvisionIP               *string           `mapstructure:"provision_ip" cty:"provision_ip" hcl:"provision_ip"`
-	ProvisionMac              *string           `mapstructure:"provision_mac" cty:"provision_mac" hcl:"provision_mac"`
-	ProvisionPort             *int              `mapstructure:"provision_port" cty:"provision_port" hcl:"provision_port"`
-	ProvisionPublicKeyPath    *string           `mapstructure:"provision_public_key_file" cty:"provision_public_key_file" hcl:"provision_public_key_file"`
-	ProvisionPrivateKeyPath   *string           `mapstructure:"provision_private_key_file" cty:"provision_private_key_file" hcl:"provision_private_key_file"`
-	ProvisionPassword         *string           `mapstructure:"provision_password" cty:"provision_password" hcl:"provision_password"`
+	PackerBuildName              *string                `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType            *string                `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion            *string                `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug                  *bool                  `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce                  *bool                  `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError                *string                `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars               map[string]string      `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars          []string               `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HTTPDir                      *string                `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
+	HTTPContent                  map[string]string      `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
+	HTTPPortMin                  *int                   `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
+	HTTPPortMax                  *int                   `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
+	HTTPAddress                  *string                `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
+	HTTPInterface                *string                `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	BootGroupInterval            *string                `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait                     *string                `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand                  []string               `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	Type                         *string                `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect           *string                `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                      *string                `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                      *int                   `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername                  *string                `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword                  *string                `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName               *string                `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName      *string                `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType      *string                `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits      *int                   `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                   []string               `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys       *bool                  `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos                  []string               `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile            *string                `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile           *string                `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                       *bool                  `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                   *string                `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout               *string                `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth                 *bool                  `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding    *bool                  `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts         *int                   `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost               *string                `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort               *int                   `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth          *bool                  `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername           *string                `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword           *string                `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive        *bool                  `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile     *string                `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile    *string                `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod        *string                `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost                 *string                `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort                 *int                   `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername             *string                `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword             *string                `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval         *string                `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout          *string                `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels             []string               `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels              []string               `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey                 []byte                 `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey                []byte                 `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                    *string                `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword                *string                `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                    *string                `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy                 *bool                  `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                    *int                   `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout                 *string                `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL                  *bool                  `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure                *bool                  `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM                 *bool                  `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	BootKeyInterval              *string                `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	ProxmoxURLRaw                *string                `mapstructure:"proxmox_url" cty:"proxmox_url" hcl:"proxmox_url"`
+	SkipCertValidation           *bool                  `mapstructure:"insecure_skip_tls_verify" cty:"insecure_skip_tls_verify" hcl:"insecure_skip_tls_verify"`
+	ProxmoxCAFile                *string                `mapstructure:"proxmox_ca_file" cty:"proxmox_ca_file" hcl:"proxmox_ca_file"`
+	ProxmoxCAPEM                 *string                `mapstructure:"proxmox_ca_pem" cty:"proxmox_ca_pem" hcl:"proxmox_ca_pem"`
+	TLSFingerprint               *string                `mapstructure:"proxmox_tls_fingerprint" cty:"proxmox_tls_fingerprint" hcl:"proxmox_tls_fingerprint"`
+	Username                     *string                `mapstructure:"username" cty:"username" hcl:"username"`
+	Password                     *string                `mapstructure:"password" cty:"password" hcl:"password"`
+	TokenID                      *string                `mapstructure:"token_id" cty:"token_id" hcl:"token_id"`
+	TokenSecret                  *string                `mapstructure:"token_secret" cty:"token_secret" hcl:"token_secret"`
+	OTP                          *string                `mapstructure:"otp" cty:"otp" hcl:"otp"`
+	OTPSecret                    *string                `mapstructure:"otp_secret" cty:"otp_secret" hcl:"otp_secret"`
+	Node                         *string                `mapstructure:"node" cty:"node" hcl:"node"`
+	Pool                         *string                `mapstructure:"pool" cty:"pool" hcl:"pool"`
+	NodeCandidates               []string               `mapstructure:"node_candidates" cty:"node_candidates" hcl:"node_candidates"`
+	NodeLeastRecentlyUsed        *bool                  `mapstructure:"node_least_recently_used" cty:"node_least_recently_used" hcl:"node_least_recently_used"`
+	Memory                       *int                   `mapstructure:"memory" cty:"memory" hcl:"memory"`
+	Cores                        *int                   `mapstructure:"cores" cty:"cores" hcl:"cores"`
+	Unprivileged                 *bool                  `mapstructure:"unprivileged" cty:"unprivileged" hcl:"unprivileged"`
+	TemplateFile                 *string                `mapstructure:"template_file" cty:"template_file" hcl:"template_file"`
+	TemplateAppliance            *string                `mapstructure:"template_appliance" cty:"template_appliance" hcl:"template_appliance"`
+	TemplateURL                  *string                `mapstructure:"template_url" cty:"template_url" hcl:"template_url"`
+	TemplateChecksum             *string                `mapstructure:"template_checksum" cty:"template_checksum" hcl:"template_checksum"`
+	TemplateLocalFile            *string                `mapstructure:"template_local_file" cty:"template_local_file" hcl:"template_local_file"`
+	RemoveUploaded               *bool                  `mapstructure:"remove_uploaded_template" cty:"remove_uploaded_template" hcl:"remove_uploaded_template"`
+	TemplateStoragePool          *string                `mapstructure:"template_storage_pool" cty:"template_storage_pool" hcl:"template_storage_pool"`
+	FSStorage                    *string                `mapstructure:"filesystem_storage" cty:"filesystem_storage" hcl:"filesystem_storage"`
+	FSSize                       *int                   `mapstructure:"filesystem_size" cty:"filesystem_size" hcl:"filesystem_size"`
+	VMID                         *int                   `mapstructure:"vmid" cty:"vmid" hcl:"vmid"`
+	VMIDRange                    *string                `mapstructure:"vmid_range" cty:"vmid_range" hcl:"vmid_range"`
+	Swap                         *int                   `mapstructure:"swap" cty:"swap" hcl:"swap"`
+	CPULimit                     *int                   `mapstructure:"cpulimit" cty:"cpulimit" hcl:"cpulimit"`
+	CPUUnits                     *int                   `mapstructure:"cpuunits" cty:"cpuunits" hcl:"cpuunits"`
+	Arch                         *string                `mapstructure:"arch" cty:"arch" hcl:"arch"`
+	OSType                       *string                `mapstructure:"ostype" cty:"ostype" hcl:"ostype"`
+	Hostname                     *string                `mapstructure:"hostname" cty:"hostname" hcl:"hostname"`
+	Nameserver                   *string                `mapstructure:"nameserver" cty:"nameserver" hcl:"nameserver"`
+	SearchDomain                 *string                `mapstructure:"searchdomain" cty:"searchdomain" hcl:"searchdomain"`
+	Timezone                     *string                `mapstructure:"timezone" cty:"timezone" hcl:"timezone"`
+	Tags                         []string               `mapstructure:"tags" cty:"tags" hcl:"tags"`
+	Description                  *string                `mapstructure:"description" cty:"description" hcl:"description"`
+	ConsoleMode                  *string                `mapstructure:"console_mode" cty:"console_mode" hcl:"console_mode"`
+	TTY                          *int                   `mapstructure:"tty" cty:"tty" hcl:"tty"`
+	Features                     *FlatfeaturesConfig    `mapstructure:"features" cty:"features" hcl:"features"`
+	CloneVMID                    *int                   `mapstructure:"clone_vmid" cty:"clone_vmid" hcl:"clone_vmid"`
+	CloneName                    *string                `mapstructure:"clone_name" cty:"clone_name" hcl:"clone_name"`
+	LinkedClone                  *bool                  `mapstructure:"linked_clone" cty:"linked_clone" hcl:"linked_clone"`
+	NICs                         []FlatnicConfig        `mapstructure:"network_adapters" cty:"network_adapters" hcl:"network_adapters"`
+	MountPoints                  []FlatmountPointConfig `mapstructure:"mount_point" cty:"mount_point" hcl:"mount_point"`
+	Devices                      []FlatdeviceConfig     `mapstructure:"devices" cty:"devices" hcl:"devices"`
+	RawConfig                    []string               `mapstructure:"lxc_raw_config" cty:"lxc_raw_config" hcl:"lxc_raw_config"`
+	NodeCommandMethod            *string                `mapstructure:"node_command_method" cty:"node_command_method" hcl:"node_command_method"`
+	NodeSSHKnownHosts            *string                `mapstructure:"node_ssh_known_hosts_file" cty:"node_ssh_known_hosts_file" hcl:"node_ssh_known_hosts_file"`
+	NodeSSHHostKey               *string                `mapstructure:"node_ssh_host_key" cty:"node_ssh_host_key" hcl:"node_ssh_host_key"`
+	BootstrapCommands            []string               `mapstructure:"bootstrap_commands" cty:"bootstrap_commands" hcl:"bootstrap_commands"`
+	NodeSSHHost                  *string                `mapstructure:"node_ssh_host" cty:"node_ssh_host" hcl:"node_ssh_host"`
+	NodeSSHUsername              *string                `mapstructure:"node_ssh_username" cty:"node_ssh_username" hcl:"node_ssh_username"`
+	NodeSSHPort                  *int                   `mapstructure:"node_ssh_port" cty:"node_ssh_port" hcl:"node_ssh_port"`
+	NodeSSHPassword              *string                `mapstructure:"node_ssh_password" cty:"node_ssh_password" hcl:"node_ssh_password"`
+	NodeSSHPrivateKeyFile        *string                `mapstructure:"node_ssh_private_key_file" cty:"node_ssh_private_key_file" hcl:"node_ssh_private_key_file"`
+	NodeSSHAgentAuth             *bool                  `mapstructure:"node_ssh_agent_auth" cty:"node_ssh_agent_auth" hcl:"node_ssh_agent_auth"`
+	NodeSSHBastionHost           *string                `mapstructure:"node_ssh_bastion_host" cty:"node_ssh_bastion_host" hcl:"node_ssh_bastion_host"`
+	NodeSSHBastionPort           *int                   `mapstructure:"node_ssh_bastion_port" cty:"node_ssh_bastion_port" hcl:"node_ssh_bastion_port"`
+	NodeSSHBastionUsername       *string                `mapstructure:"node_ssh_bastion_username" cty:"node_ssh_bastion_username" hcl:"node_ssh_bastion_username"`
+	NodeSSHBastionPassword       *string                `mapstructure:"node_ssh_bastion_password" cty:"node_ssh_bastion_password" hcl:"node_ssh_bastion_password"`
+	NodeSSHBastionPrivateKeyFile *string                `mapstructure:"node_ssh_bastion_private_key_file" cty:"node_ssh_bastion_private_key_file" hcl:"node_ssh_bastion_private_key_file"`
+	NodeSSHBastionAgentAuth      *bool                  `mapstructure:"node_ssh_bastion_agent_auth" cty:"node_ssh_bastion_agent_auth" hcl:"node_ssh_bastion_agent_auth"`
+	NodeSSHBastionHostKey        *string                `mapstructure:"node_ssh_bastion_host_key" cty:"node_ssh_bastion_host_key" hcl:"node_ssh_bastion_host_key"`
+	OutputMode                   *string                `mapstructure:"output_mode" cty:"output_mode" hcl:"output_mode"`
+	OutputPath                   *string                `mapstructure:"output_path" cty:"output_path" hcl:"output_path"`
+	BackupStorage                *string                `mapstructure:"backup_storage" cty:"backup_storage" hcl:"backup_storage"`
+	BackupMode                   *string                `mapstructure:"backup_mode" cty:"backup_mode" hcl:"backup_mode"`
+	BackupCompression            *string                `mapstructure:"backup_compression" cty:"backup_compression" hcl:"backup_compression"`
+	BackupZstdThreads            *int                   `mapstructure:"backup_zstd_threads" cty:"backup_zstd_threads" hcl:"backup_zstd_threads"`
+	BackupBandwidthLimit         *int                   `mapstructure:"backup_bwlimit" cty:"backup_bwlimit" hcl:"backup_bwlimit"`
+	ProvisionIP                  *string                `mapstructure:"provision_ip" cty:"provision_ip" hcl:"provision_ip"`
+	ProvisionInterface           *string                `mapstructure:"provision_interface" cty:"provision_interface" hcl:"provision_interface"`
+	ProvisionCIDR                *string                `mapstructure:"provision_cidr" cty:"provision_cidr" hcl:"provision_cidr"`
+	ProvisionMac                 *string                `mapstructure:"provision_mac" cty:"provision_mac" hcl:"provision_mac"`
+	ProvisionPort                *int                   `mapstructure:"provision_port" cty:"provision_port" hcl:"provision_port"`
+	ProvisionPublicKeyPath       *string                `mapstructure:"provision_public_key_file" cty:"provision_public_key_file" hcl:"provision_public_key_file"`
+	ProvisionPrivateKeyPath      *string                `mapstructure:"provision_private_key_file" cty:"provision_private_key_file" hcl:"provision_private_key_file"`
+	ProvisionPassword            *string                `mapstructure:"provision_password" cty:"provision_password" hcl:"provision_password"`
+	TaskTimeout                  *string                `mapstructure:"task_timeout" cty:"task_timeout" hcl:"task_timeout"`
+	ProvisionIPTimeout           *string                `mapstructure:"provision_ip_timeout" cty:"provision_ip_timeout" hcl:"provision_ip_timeout"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -106,89 +175,301 @@ func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatConfig.
 func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"packer_build_name":            &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
-		"packer_builder_type":          &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
-		"packer_debug":                 &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
-		"packer_force":                 &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
-		"packer_on_error":              &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
-		"packer_user_variables":        &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
-		"packer_sensitive_variables":   &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
-		"http_directory":               &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
-		"http_port_min":                &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
-		"http_port_max":                &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
-		"http_bind_address":            &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
-		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
-		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
-		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
-		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
-		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
-		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                     &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                 &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                 &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":             &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":      &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"ssh_ciphers":                  &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":    &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":  &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":         &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":         &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                      &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                  &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":             &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":               &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding": &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":       &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":             &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":             &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":         &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":         &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":      &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file": &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":     &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":               &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":               &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":           &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":           &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":      &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":       &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":           &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":            &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":               &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":              &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
-		"winrm_username":               &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password":               &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":                   &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy":               &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":                   &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":                &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
-		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
-		"proxmox_url":                  &hcldec.AttrSpec{Name: "proxmox_url", Type: cty.String, Required: false},
-		"insecure_skip_tls_verify":     &hcldec.AttrSpec{Name: "insecure_skip_tls_verify", Type: cty.Bool, Required: false},
-		"username":                     &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
-		"password":                     &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
-		"node":                         &hcldec.AttrSpec{Name: "node", Type: cty.String, Required: false},
-		"pool":                         &hcldec.AttrSpec{Name: "pool", Type: cty.String, Required: false},
-		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
-		"cores":                        &hcldec.AttrSpec{Name: "cores", Type: cty.Number, Required: false},
-		"unprivileged":                 &hcldec.AttrSpec{Name: "unprivileged", Type: cty.Bool, Required: false},
-		"template_file":                &hcldec.AttrSpec{Name: "template_file", Type: cty.String, Required: false},
-		"template_storage_pool":        &hcldec.AttrSpec{Name: "template_storage_pool", Type: cty.String, Required: false},
-		"filesystem_storage":           &hcldec.AttrSpec{Name: "filesystem_storage", Type: cty.String, Required: false},
-		"filesystem_size":              &hcldec.AttrSpec{Name: "filesystem_size", Type: cty.Number, Required: false},
-		"vmid":                         &hcldec.AttrSpec{Name: "vmid", Type: cty.Number, Required: false},
-		"output_path":                  &hcldec.AttrSpec{Name: "output_path", Type: cty.String, Required: false},
-		"provision_ip":                 &hcldec.AttrSpec{Name: "provision_ip", Type: cty.String, Required: false},
-		"provision_mac":                &hcldec.AttrSpec{Name: "provision_mac", Type: cty.String, Required: false},
-		"provision_port":               &hcldec.AttrSpec{Name: "provision_port", Type: cty.Number, Required: false},
-		"provision_public_key_file":    &hcldec.AttrSpec{Name: "provision_public_key_file", Type: cty.String, Required: false},
-		"provision_private_key_file":   &hcldec.AttrSpec{Name: "provision_private_key_file", Type: cty.String, Required: false},
-		"provision_password":           &hcldec.AttrSpec{Name: "provision_password", Type: cty.String, Required: false},
+		"packer_build_name":                 &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":               &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":               &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":                      &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":                      &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":                   &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":             &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables":        &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"http_directory":                    &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
+		"http_content":                      &hcldec.AttrSpec{Name: "http_content", Type: cty.Map(cty.String), Required: false},
+		"http_port_min":                     &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
+		"http_port_max":                     &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
+		"http_bind_address":                 &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
+		"http_interface":                    &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
+		"boot_keygroup_interval":            &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
+		"boot_wait":                         &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
+		"boot_command":                      &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"communicator":                      &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":           &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"ssh_host":                          &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                          &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                      &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                      &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keypair_name":                  &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":           &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":           &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":           &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                       &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":         &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":       &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_private_key_file":              &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":              &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                           &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_timeout":                       &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":                  &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                    &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":      &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":            &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":                  &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":                  &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":            &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":              &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":              &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":           &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":      &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":      &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":          &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_proxy_host":                    &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_port":                    &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":                &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":                &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":           &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_read_write_timeout":            &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":                &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":                 &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                    &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                   &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":                    &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":                    &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                        &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":                    &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_port":                        &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                     &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                     &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":                    &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":                    &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"boot_key_interval":                 &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"proxmox_url":                       &hcldec.AttrSpec{Name: "proxmox_url", Type: cty.String, Required: false},
+		"insecure_skip_tls_verify":          &hcldec.AttrSpec{Name: "insecure_skip_tls_verify", Type: cty.Bool, Required: false},
+		"proxmox_ca_file":                   &hcldec.AttrSpec{Name: "proxmox_ca_file", Type: cty.String, Required: false},
+		"proxmox_ca_pem":                    &hcldec.AttrSpec{Name: "proxmox_ca_pem", Type: cty.String, Required: false},
+		"proxmox_tls_fingerprint":           &hcldec.AttrSpec{Name: "proxmox_tls_fingerprint", Type: cty.String, Required: false},
+		"username":                          &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":                          &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"token_id":                          &hcldec.AttrSpec{Name: "token_id", Type: cty.String, Required: false},
+		"token_secret":                      &hcldec.AttrSpec{Name: "token_secret", Type: cty.String, Required: false},
+		"otp":                               &hcldec.AttrSpec{Name: "otp", Type: cty.String, Required: false},
+		"otp_secret":                        &hcldec.AttrSpec{Name: "otp_secret", Type: cty.String, Required: false},
+		"node":                              &hcldec.AttrSpec{Name: "node", Type: cty.String, Required: false},
+		"pool":                              &hcldec.AttrSpec{Name: "pool", Type: cty.String, Required: false},
+		"node_candidates":                   &hcldec.AttrSpec{Name: "node_candidates", Type: cty.List(cty.String), Required: false},
+		"node_least_recently_used":          &hcldec.AttrSpec{Name: "node_least_recently_used", Type: cty.Bool, Required: false},
+		"memory":                            &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
+		"cores":                             &hcldec.AttrSpec{Name: "cores", Type: cty.Number, Required: false},
+		"unprivileged":                      &hcldec.AttrSpec{Name: "unprivileged", Type: cty.Bool, Required: false},
+		"template_file":                     &hcldec.AttrSpec{Name: "template_file", Type: cty.String, Required: false},
+		"template_appliance":                &hcldec.AttrSpec{Name: "template_appliance", Type: cty.String, Required: false},
+		"template_url":                      &hcldec.AttrSpec{Name: "template_url", Type: cty.String, Required: false},
+		"template_checksum":                 &hcldec.AttrSpec{Name: "template_checksum", Type: cty.String, Required: false},
+		"template_local_file":               &hcldec.AttrSpec{Name: "template_local_file", Type: cty.String, Required: false},
+		"remove_uploaded_template":          &hcldec.AttrSpec{Name: "remove_uploaded_template", Type: cty.Bool, Required: false},
+		"template_storage_pool":             &hcldec.AttrSpec{Name: "template_storage_pool", Type: cty.String, Required: false},
+		"filesystem_storage":                &hcldec.AttrSpec{Name: "filesystem_storage", Type: cty.String, Required: false},
+		"filesystem_size":                   &hcldec.AttrSpec{Name: "filesystem_size", Type: cty.Number, Required: false},
+		"vmid":                              &hcldec.AttrSpec{Name: "vmid", Type: cty.Number, Required: false},
+		"vmid_range":                        &hcldec.AttrSpec{Name: "vmid_range", Type: cty.String, Required: false},
+		"swap":                              &hcldec.AttrSpec{Name: "swap", Type: cty.Number, Required: false},
+		"cpulimit":                          &hcldec.AttrSpec{Name: "cpulimit", Type: cty.Number, Required: false},
+		"cpuunits":                          &hcldec.AttrSpec{Name: "cpuunits", Type: cty.Number, Required: false},
+		"arch":                              &hcldec.AttrSpec{Name: "arch", Type: cty.String, Required: false},
+		"ostype":                            &hcldec.AttrSpec{Name: "ostype", Type: cty.String, Required: false},
+		"hostname":                          &hcldec.AttrSpec{Name: "hostname", Type: cty.String, Required: false},
+		"nameserver":                        &hcldec.AttrSpec{Name: "nameserver", Type: cty.String, Required: false},
+		"searchdomain":                      &hcldec.AttrSpec{Name: "searchdomain", Type: cty.String, Required: false},
+		"timezone":                          &hcldec.AttrSpec{Name: "timezone", Type: cty.String, Required: false},
+		"tags":                              &hcldec.AttrSpec{Name: "tags", Type: cty.List(cty.String), Required: false},
+		"description":                       &hcldec.AttrSpec{Name: "description", Type: cty.String, Required: false},
+		"console_mode":                      &hcldec.AttrSpec{Name: "console_mode", Type: cty.String, Required: false},
+		"tty":                               &hcldec.AttrSpec{Name: "tty", Type: cty.Number, Required: false},
+		"features":                          &hcldec.BlockSpec{TypeName: "features", Nested: hcldec.ObjectSpec((*FlatfeaturesConfig)(nil).HCL2Spec())},
+		"clone_vmid":                        &hcldec.AttrSpec{Name: "clone_vmid", Type: cty.Number, Required: false},
+		"clone_name":                        &hcldec.AttrSpec{Name: "clone_name", Type: cty.String, Required: false},
+		"linked_clone":                      &hcldec.AttrSpec{Name: "linked_clone", Type: cty.Bool, Required: false},
+		"network_adapters":                  &hcldec.BlockListSpec{TypeName: "network_adapters", Nested: hcldec.ObjectSpec((*FlatnicConfig)(nil).HCL2Spec())},
+		"mount_point":                       &hcldec.BlockListSpec{TypeName: "mount_point", Nested: hcldec.ObjectSpec((*FlatmountPointConfig)(nil).HCL2Spec())},
+		"devices":                           &hcldec.BlockListSpec{TypeName: "devices", Nested: hcldec.ObjectSpec((*FlatdeviceConfig)(nil).HCL2Spec())},
+		"lxc_raw_config":                    &hcldec.AttrSpec{Name: "lxc_raw_config", Type: cty.List(cty.String), Required: false},
+		"node_command_method":               &hcldec.AttrSpec{Name: "node_command_method", Type: cty.String, Required: false},
+		"node_ssh_known_hosts_file":         &hcldec.AttrSpec{Name: "node_ssh_known_hosts_file", Type: cty.String, Required: false},
+		"node_ssh_host_key":                 &hcldec.AttrSpec{Name: "node_ssh_host_key", Type: cty.String, Required: false},
+		"bootstrap_commands":                &hcldec.AttrSpec{Name: "bootstrap_commands", Type: cty.List(cty.String), Required: false},
+		"node_ssh_host":                     &hcldec.AttrSpec{Name: "node_ssh_host", Type: cty.String, Required: false},
+		"node_ssh_username":                 &hcldec.AttrSpec{Name: "node_ssh_username", Type: cty.String, Required: false},
+		"node_ssh_port":                     &hcldec.AttrSpec{Name: "node_ssh_port", Type: cty.Number, Required: false},
+		"node_ssh_password":                 &hcldec.AttrSpec{Name: "node_ssh_password", Type: cty.String, Required: false},
+		"node_ssh_private_key_file":         &hcldec.AttrSpec{Name: "node_ssh_private_key_file", Type: cty.String, Required: false},
+		"node_ssh_agent_auth":               &hcldec.AttrSpec{Name: "node_ssh_agent_auth", Type: cty.Bool, Required: false},
+		"node_ssh_bastion_host":             &hcldec.AttrSpec{Name: "node_ssh_bastion_host", Type: cty.String, Required: false},
+		"node_ssh_bastion_port":             &hcldec.AttrSpec{Name: "node_ssh_bastion_port", Type: cty.Number, Required: false},
+		"node_ssh_bastion_username":         &hcldec.AttrSpec{Name: "node_ssh_bastion_username", Type: cty.String, Required: false},
+		"node_ssh_bastion_password":         &hcldec.AttrSpec{Name: "node_ssh_bastion_password", Type: cty.String, Required: false},
+		"node_ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "node_ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"node_ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "node_ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"node_ssh_bastion_host_key":         &hcldec.AttrSpec{Name: "node_ssh_bastion_host_key", Type: cty.String, Required: false},
+		"output_mode":                       &hcldec.AttrSpec{Name: "output_mode", Type: cty.String, Required: false},
+		"output_path":                       &hcldec.AttrSpec{Name: "output_path", Type: cty.String, Required: false},
+		"backup_storage":                    &hcldec.AttrSpec{Name: "backup_storage", Type: cty.String, Required: false},
+		"backup_mode":                       &hcldec.AttrSpec{Name: "backup_mode", Type: cty.String, Required: false},
+		"backup_compression":                &hcldec.AttrSpec{Name: "backup_compression", Type: cty.String, Required: false},
+		"backup_zstd_threads":               &hcldec.AttrSpec{Name: "backup_zstd_threads", Type: cty.Number, Required: false},
+		"backup_bwlimit":                    &hcldec.AttrSpec{Name: "backup_bwlimit", Type: cty.Number, Required: false},
+		"provision_ip":                      &hcldec.AttrSpec{Name: "provision_ip", Type: cty.String, Required: false},
+		"provision_interface":               &hcldec.AttrSpec{Name: "provision_interface", Type: cty.String, Required: false},
+		"provision_cidr":                    &hcldec.AttrSpec{Name: "provision_cidr", Type: cty.String, Required: false},
+		"provision_mac":                     &hcldec.AttrSpec{Name: "provision_mac", Type: cty.String, Required: false},
+		"provision_port":                    &hcldec.AttrSpec{Name: "provision_port", Type: cty.Number, Required: false},
+		"provision_public_key_file":         &hcldec.AttrSpec{Name: "provision_public_key_file", Type: cty.String, Required: false},
+		"provision_private_key_file":        &hcldec.AttrSpec{Name: "provision_private_key_file", Type: cty.String, Required: false},
+		"provision_password":                &hcldec.AttrSpec{Name: "provision_password", Type: cty.String, Required: false},
+		"task_timeout":                      &hcldec.AttrSpec{Name: "task_timeout", Type: cty.String, Required: false},
+		"provision_ip_timeout":              &hcldec.AttrSpec{Name: "provision_ip_timeout", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatdeviceConfig is an auto-generated flat version of deviceConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatdeviceConfig struct {
+	Path *string `mapstructure:"path" cty:"path" hcl:"path"`
+	Mode *string `mapstructure:"mode" cty:"mode" hcl:"mode"`
+	UID  *int    `mapstructure:"uid" cty:"uid" hcl:"uid"`
+	GID  *int    `mapstructure:"gid" cty:"gid" hcl:"gid"`
+}
+
+// FlatMapstructure returns a new FlatdeviceConfig.
+// FlatdeviceConfig is an auto-generated flat version of deviceConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*deviceConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatdeviceConfig)
+}
+
+// HCL2Spec returns the hcl spec of a deviceConfig.
+// This spec is used by HCL to read the fields of deviceConfig.
+// The decoded values from this spec will then be applied to a FlatdeviceConfig.
+func (*FlatdeviceConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"path": &hcldec.AttrSpec{Name: "path", Type: cty.String, Required: false},
+		"mode": &hcldec.AttrSpec{Name: "mode", Type: cty.String, Required: false},
+		"uid":  &hcldec.AttrSpec{Name: "uid", Type: cty.Number, Required: false},
+		"gid":  &hcldec.AttrSpec{Name: "gid", Type: cty.Number, Required: false},
+	}
+	return s
+}
+
+// FlatfeaturesConfig is an auto-generated flat version of featuresConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatfeaturesConfig struct {
+	Nesting *bool    `mapstructure:"nesting" cty:"nesting" hcl:"nesting"`
+	Keyctl  *bool    `mapstructure:"keyctl" cty:"keyctl" hcl:"keyctl"`
+	Fuse    *bool    `mapstructure:"fuse" cty:"fuse" hcl:"fuse"`
+	Mknod   *bool    `mapstructure:"mknod" cty:"mknod" hcl:"mknod"`
+	Mount   []string `mapstructure:"mount" cty:"mount" hcl:"mount"`
+}
+
+// FlatMapstructure returns a new FlatfeaturesConfig.
+// FlatfeaturesConfig is an auto-generated flat version of featuresConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*featuresConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatfeaturesConfig)
+}
+
+// HCL2Spec returns the hcl spec of a featuresConfig.
+// This spec is used by HCL to read the fields of featuresConfig.
+// The decoded values from this spec will then be applied to a FlatfeaturesConfig.
+func (*FlatfeaturesConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"nesting": &hcldec.AttrSpec{Name: "nesting", Type: cty.Bool, Required: false},
+		"keyctl":  &hcldec.AttrSpec{Name: "keyctl", Type: cty.Bool, Required: false},
+		"fuse":    &hcldec.AttrSpec{Name: "fuse", Type: cty.Bool, Required: false},
+		"mknod":   &hcldec.AttrSpec{Name: "mknod", Type: cty.Bool, Required: false},
+		"mount":   &hcldec.AttrSpec{Name: "mount", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
+
+// FlatmountPointConfig is an auto-generated flat version of mountPointConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatmountPointConfig struct {
+	Storage      *string  `mapstructure:"storage" cty:"storage" hcl:"storage"`
+	Size         *int     `mapstructure:"size" cty:"size" hcl:"size"`
+	HostPath     *string  `mapstructure:"host_path" cty:"host_path" hcl:"host_path"`
+	Path         *string  `mapstructure:"path" cty:"path" hcl:"path"`
+	ReadOnly     *bool    `mapstructure:"read_only" cty:"read_only" hcl:"read_only"`
+	Backup       *bool    `mapstructure:"backup" cty:"backup" hcl:"backup"`
+	Quota        *bool    `mapstructure:"quota" cty:"quota" hcl:"quota"`
+	ACL          *bool    `mapstructure:"acl" cty:"acl" hcl:"acl"`
+	MountOptions []string `mapstructure:"mount_options" cty:"mount_options" hcl:"mount_options"`
+	Detach       *bool    `mapstructure:"detach_before_vzdump" cty:"detach_before_vzdump" hcl:"detach_before_vzdump"`
+}
+
+// FlatMapstructure returns a new FlatmountPointConfig.
+// FlatmountPointConfig is an auto-generated flat version of mountPointConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*mountPointConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatmountPointConfig)
+}
+
+// HCL2Spec returns the hcl spec of a mountPointConfig.
+// This spec is used by HCL to read the fields of mountPointConfig.
+// The decoded values from this spec will then be applied to a FlatmountPointConfig.
+func (*FlatmountPointConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"storage":              &hcldec.AttrSpec{Name: "storage", Type: cty.String, Required: false},
+		"size":                 &hcldec.AttrSpec{Name: "size", Type: cty.Number, Required: false},
+		"host_path":            &hcldec.AttrSpec{Name: "host_path", Type: cty.String, Required: false},
+		"path":                 &hcldec.AttrSpec{Name: "path", Type: cty.String, Required: false},
+		"read_only":            &hcldec.AttrSpec{Name: "read_only", Type: cty.Bool, Required: false},
+		"backup":               &hcldec.AttrSpec{Name: "backup", Type: cty.Bool, Required: false},
+		"quota":                &hcldec.AttrSpec{Name: "quota", Type: cty.Bool, Required: false},
+		"acl":                  &hcldec.AttrSpec{Name: "acl", Type: cty.Bool, Required: false},
+		"mount_options":        &hcldec.AttrSpec{Name: "mount_options", Type: cty.List(cty.String), Required: false},
+		"detach_before_vzdump": &hcldec.AttrSpec{Name: "detach_before_vzdump", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatnicConfig is an auto-generated flat version of nicConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatnicConfig struct {
+	Name       *string  `mapstructure:"name" cty:"name" hcl:"name"`
+	Bridge     *string  `mapstructure:"bridge" cty:"bridge" hcl:"bridge"`
+	VLANTag    *int     `mapstructure:"vlan_tag" cty:"vlan_tag" hcl:"vlan_tag"`
+	MTU        *int     `mapstructure:"mtu" cty:"mtu" hcl:"mtu"`
+	Rate       *float64 `mapstructure:"rate" cty:"rate" hcl:"rate"`
+	Firewall   *bool    `mapstructure:"firewall" cty:"firewall" hcl:"firewall"`
+	IP         *string  `mapstructure:"ip" cty:"ip" hcl:"ip"`
+	Gateway    *string  `mapstructure:"gateway" cty:"gateway" hcl:"gateway"`
+	IP6        *string  `mapstructure:"ip6" cty:"ip6" hcl:"ip6"`
+	Gateway6   *string  `mapstructure:"gateway6" cty:"gateway6" hcl:"gateway6"`
+	MACAddress *string  `mapstructure:"mac_address" cty:"mac_address" hcl:"mac_address"`
+}
+
+// FlatMapstructure returns a new FlatnicConfig.
+// FlatnicConfig is an auto-generated flat version of nicConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*nicConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatnicConfig)
+}
+
+// HCL2Spec returns the hcl spec of a nicConfig.
+// This spec is used by HCL to read the fields of nicConfig.
+// The decoded values from this spec will then be applied to a FlatnicConfig.
+func (*FlatnicConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"name":        &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"bridge":      &hcldec.AttrSpec{Name: "bridge", Type: cty.String, Required: false},
+		"vlan_tag":    &hcldec.AttrSpec{Name: "vlan_tag", Type: cty.Number, Required: false},
+		"mtu":         &hcldec.AttrSpec{Name: "mtu", Type: cty.Number, Required: false},
+		"rate":        &hcldec.AttrSpec{Name: "rate", Type: cty.Number, Required: false},
+		"firewall":    &hcldec.AttrSpec{Name: "firewall", Type: cty.Bool, Required: false},
+		"ip":          &hcldec.AttrSpec{Name: "ip", Type: cty.String, Required: false},
+		"gateway":     &hcldec.AttrSpec{Name: "gateway", Type: cty.String, Required: false},
+		"ip6":         &hcldec.AttrSpec{Name: "ip6", Type: cty.String, Required: false},
+		"gateway6":    &hcldec.AttrSpec{Name: "gateway6", Type: cty.String, Required: false},
+		"mac_address": &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
 	}
 	return s
 }
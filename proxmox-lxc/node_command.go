@@ -0,0 +1,227 @@
+package proxmox_lxc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// nodeRunner runs shell commands on the Proxmox node hosting the container,
+// either over SSH or, when Packer runs on the node itself, locally.
+type nodeRunner interface {
+	Run(ctx context.Context, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
+	Close() error
+}
+
+// newNodeRunner returns the nodeRunner selected by node_command_method.
+func newNodeRunner(c *Config) (nodeRunner, error) {
+	if c.NodeCommandMethod == nodeCommandLocal {
+		return &localNodeRunner{}, nil
+	}
+	client, err := dialNode(c)
+	if err != nil {
+		return nil, err
+	}
+	return &sshNodeRunner{client: client}, nil
+}
+
+// dialNode opens an SSH connection to the Proxmox node, through the bastion
+// host if one is configured.
+func dialNode(c *Config) (*ssh.Client, error) {
+	auth, err := sshAuthMethods(c.NodeSSHPrivateKeyFile, c.NodeSSHAgentAuth, c.nodeSSHPassword())
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User: c.NodeSSHUsername,
+		Auth: auth,
+	}
+	if config.HostKeyCallback, err = c.hostKeyCallback(c.NodeSSHHostKey); err != nil {
+		return nil, err
+	}
+
+	host := c.proxmoxURL.Hostname()
+	if c.nodeAddress != "" {
+		host = c.nodeAddress
+	}
+	sshAddr := net.JoinHostPort(host, strconv.Itoa(c.NodeSSHPort))
+
+	if c.NodeSSHBastionHost == "" {
+		client, err := ssh.Dial("tcp", sshAddr, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s as %s: %s", sshAddr, config.User, err)
+		}
+		return client, nil
+	}
+
+	bastionAuth, err := sshAuthMethods(c.NodeSSHBastionPrivateKeyFile, c.NodeSSHBastionAgentAuth, c.NodeSSHBastionPassword)
+	if err != nil {
+		return nil, err
+	}
+	bastionConfig := &ssh.ClientConfig{
+		User: c.NodeSSHBastionUsername,
+		Auth: bastionAuth,
+	}
+	if bastionConfig.HostKeyCallback, err = c.hostKeyCallback(c.NodeSSHBastionHostKey); err != nil {
+		return nil, err
+	}
+	bastionAddr := net.JoinHostPort(c.NodeSSHBastionHost, strconv.Itoa(c.NodeSSHBastionPort))
+	bastion, err := ssh.Dial("tcp", bastionAddr, bastionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bastion host %s as %s: %s", bastionAddr, bastionConfig.User, err)
+	}
+
+	conn, err := bastion.Dial("tcp", sshAddr)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("failed to reach %s through bastion host %s: %s", sshAddr, bastionAddr, err)
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, sshAddr, config)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("failed to connect to %s as %s: %s", sshAddr, config.User, err)
+	}
+	client := ssh.NewClient(clientConn, chans, reqs)
+	// The bastion connection lives as long as the one to the node
+	go func() {
+		client.Wait()
+		bastion.Close()
+	}()
+	return client, nil
+}
+
+// sshAuthMethods returns the authentication methods for a private key file,
+// the SSH agent and a password, leaving out the ones that are not configured.
+func sshAuthMethods(privateKeyFile string, agentAuth bool, password string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if privateKeyFile != "" {
+		path, err := pathing.ExpandUser(privateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH private key: %s", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH private key %s, use an agent for encrypted keys: %s", path, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if agentAuth {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, errors.New("SSH agent authentication requested, but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to SSH agent: %s", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+	}
+	return methods, nil
+}
+
+// hostKeyCallback verifies an SSH host key against the pinned fingerprint or,
+// without one, against node_ssh_known_hosts_file, so credentials are never
+// sent to an unverified host.
+func (c *Config) hostKeyCallback(pinned string) (ssh.HostKeyCallback, error) {
+	if pinned != "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if fingerprint := ssh.FingerprintSHA256(key); fingerprint != pinned {
+				return fmt.Errorf("host key mismatch for %s: got %s, expected %s", hostname, fingerprint, pinned)
+			}
+			return nil
+		}, nil
+	}
+	path, err := pathing.ExpandUser(c.NodeSSHKnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts from %s, set node_ssh_known_hosts_file or node_ssh_host_key: %s", path, err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) > 0 {
+				return fmt.Errorf("host key mismatch for %s: got %s, which does not match %s", hostname, ssh.FingerprintSHA256(key), path)
+			}
+			return fmt.Errorf("%s is not a known host in %s, add its key with ssh-keyscan or pin its fingerprint %s", hostname, path, ssh.FingerprintSHA256(key))
+		}
+		return err
+	}, nil
+}
+
+type sshNodeRunner struct {
+	client *ssh.Client
+}
+
+func (r *sshNodeRunner) Run(ctx context.Context, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		return ctx.Err()
+	}
+}
+
+func (r *sshNodeRunner) Close() error {
+	return r.client.Close()
+}
+
+type localNodeRunner struct{}
+
+func (r *localNodeRunner) Run(ctx context.Context, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func (r *localNodeRunner) Close() error {
+	return nil
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell command.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// pctExecCommand wraps command so it runs inside the container through pct exec.
+func pctExecCommand(vmId int, command string) string {
+	return fmt.Sprintf("pct exec %d -- /bin/sh -c %s", vmId, shellQuote(command))
+}
@@ -0,0 +1,184 @@
+package proxmox_lxc
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// proxmoxAuthenticator is implemented by both proxmox.Client and proxmox.Session,
+// so the same credentials can be applied to either.
+type proxmoxAuthenticator interface {
+	SetAPIToken(userID, token string)
+	Login(username string, password string, otp string) error
+}
+
+var _ proxmoxAuthenticator = &proxmox.Client{}
+var _ proxmoxAuthenticator = &proxmox.Session{}
+
+// authenticate uses the API token when one is configured, otherwise the
+// ticket of the build's login. proxmox.Client keeps its session private, so it
+// gets the ticket by logging in with it as the password, which Proxmox accepts
+// without asking for the second factor again.
+func authenticate(auth proxmoxAuthenticator, c *Config) error {
+	if c.TokenID != "" {
+		auth.SetAPIToken(c.TokenID, c.TokenSecret)
+		return nil
+	}
+	if err := c.login(); err != nil {
+		return err
+	}
+	return tlsHint(auth.Login(c.Username, c.ticket, ""))
+}
+
+// login logs in with the username, password and second factor once per build
+// and keeps the ticket and CSRF token. A one-time code can only be used once,
+// so every later session is given this ticket instead of logging in again.
+func (c *Config) login() error {
+	if c.ticket != "" {
+		return nil
+	}
+	otp := c.OTP
+	if c.OTPSecret != "" {
+		var err error
+		if otp, err = totp(c.OTPSecret, time.Now()); err != nil {
+			return fmt.Errorf("failed to compute TOTP code: %s", err)
+		}
+	}
+	session, err := proxmox.NewSession(c.proxmoxURL.String(), nil, "", c.tlsConfig())
+	if err != nil {
+		return err
+	}
+	if err := session.Login(c.Username, c.Password, otp); err != nil {
+		return tlsHint(err)
+	}
+	c.ticket = session.AuthTicket
+	c.csrfToken = session.CsrfToken
+	return nil
+}
+
+// totp computes the RFC 6238 code for the base32 encoded secret, using the
+// 30 second period and 6 digits authenticator apps and Proxmox use.
+func totp(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000), nil
+}
+
+// tlsConfig returns the TLS settings used for connections to the Proxmox API.
+// The certificate is verified against proxmox_ca_file and proxmox_ca_pem, or
+// the system roots without them. A proxmox_tls_fingerprint pin replaces the
+// system roots, so a self-signed certificate can be trusted by its
+// fingerprint alone; a configured CA is still checked in addition.
+func (c *Config) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		InsecureSkipVerify: c.SkipCertValidation,
+		RootCAs:            c.caPool,
+	}
+	if c.TLSFingerprint != "" {
+		pin := c.TLSFingerprint
+		roots := c.caPool
+		verifyChain := !c.SkipCertValidation && roots != nil
+		host := c.proxmoxURL.Hostname()
+		// The standard verification would reject a self-signed certificate
+		// before the pin is looked at, so the callback does all of it
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server sent no TLS certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if fingerprint := hex.EncodeToString(sum[:]); fingerprint != pin {
+				return fmt.Errorf("TLS certificate fingerprint %s does not match proxmox_tls_fingerprint %s", fingerprint, pin)
+			}
+			if !verifyChain {
+				return nil
+			}
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return fmt.Errorf("failed to parse TLS certificate: %s", err)
+				}
+				certs = append(certs, cert)
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := certs[0].Verify(x509.VerifyOptions{
+				DNSName:       host,
+				Roots:         roots,
+				Intermediates: intermediates,
+			})
+			return err
+		}
+	}
+	return config
+}
+
+// tlsHint adds the relevant settings to certificate verification errors.
+func tlsHint(err error) error {
+	if err != nil && strings.Contains(err.Error(), "x509: ") {
+		return fmt.Errorf("%s (set proxmox_ca_file, proxmox_ca_pem or proxmox_tls_fingerprint to trust the Proxmox certificate)", err)
+	}
+	return err
+}
+
+// newSession creates an authenticated session for the API endpoints that
+// proxmox.Client does not wrap.
+func newSession(c *Config) (*proxmox.Session, error) {
+	session, err := proxmox.NewSession(c.proxmoxURL.String(), nil, "", c.tlsConfig())
+	if err != nil {
+		return nil, err
+	}
+	if c.TokenID != "" {
+		session.SetAPIToken(c.TokenID, c.TokenSecret)
+		return session, nil
+	}
+	if err := c.login(); err != nil {
+		return nil, fmt.Errorf("failed to authenticate session: %s", err)
+	}
+	session.AuthTicket = c.ticket
+	session.CsrfToken = c.csrfToken
+	return session, nil
+}
+
+// getData performs a GET request and decodes the "data" member of the
+// response into v.
+func getData(session *proxmox.Session, path string, params *url.Values, v interface{}) error {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if _, err := session.GetJSON(path, params, nil, &envelope); err != nil {
+		return err
+	}
+	if len(envelope.Data) == 0 {
+		return fmt.Errorf("empty response from %s", path)
+	}
+	return json.Unmarshal(envelope.Data, v)
+}
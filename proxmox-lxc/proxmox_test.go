@@ -0,0 +1,39 @@
+package proxmox_lxc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B SHA-1 vectors, truncated to 6 digits. The secret is
+	// the ASCII seed "12345678901234567890".
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		code, err := totp(secret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("totp(%d): %s", tc.unix, err)
+		}
+		if code != tc.code {
+			t.Errorf("totp(%d) = %s, want %s", tc.unix, code, tc.code)
+		}
+	}
+
+	// Authenticator apps show secrets lower case and in groups
+	if code, err := totp("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); err != nil || code != "287082" {
+		t.Errorf("totp with formatted secret = %s, %v, want 287082", code, err)
+	}
+	if _, err := totp("not base32!", time.Unix(59, 0)); err == nil {
+		t.Error("totp accepted an invalid secret")
+	}
+}
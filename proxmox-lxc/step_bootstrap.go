@@ -0,0 +1,84 @@
+package proxmox_lxc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"sync"
+)
+
+// stepBootstrap runs the bootstrap_commands inside the container through pct exec
+// on the node, before the communicator connects. This allows templates without
+// an SSH server to be prepared for provisioning.
+type stepBootstrap struct{}
+
+func (s *stepBootstrap) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if len(c.BootstrapCommands) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Running bootstrap commands")
+	runner, err := newNodeRunner(c)
+	if err != nil {
+		err := fmt.Errorf("Error running bootstrap commands: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer runner.Close()
+
+	output := &uiWriter{ui: ui}
+	for _, command := range c.BootstrapCommands {
+		ui.Message(fmt.Sprintf("Executing: %s", command))
+		err := runner.Run(ctx, pctExecCommand(c.VMID, command), nil, output, output)
+		output.Flush()
+		if err != nil {
+			err := fmt.Errorf("Error running bootstrap command %q: %s", command, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepBootstrap) Cleanup(state multistep.StateBag) {}
+
+// uiWriter forwards everything written to it to the UI, one message per line.
+// It is safe to share between stdout and stderr.
+type uiWriter struct {
+	ui  packersdk.Ui
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *uiWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.ui.Message(string(bytes.TrimRight(w.buf[:i], "\r")))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush sends any remaining partial line to the UI.
+func (w *uiWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.ui.Message(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,45 @@
+package proxmox_lxc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepConnectPct is the connect step used for communicator = "pct". It opens
+// a command session on the node and sets the communicator state to a
+// pctCommunicator for the container.
+type stepConnectPct struct {
+	runner nodeRunner
+}
+
+func (s *stepConnectPct) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	// The step may run twice when pause_before_connecting is set
+	if s.runner == nil {
+		runner, err := newNodeRunner(c)
+		if err != nil {
+			err := fmt.Errorf("Error connecting to node for pct communicator: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.runner = runner
+	}
+
+	state.Put("communicator", &pctCommunicator{
+		runner: s.runner,
+		vmId:   c.VMID,
+	})
+	return multistep.ActionContinue
+}
+
+func (s *stepConnectPct) Cleanup(state multistep.StateBag) {
+	if s.runner != nil {
+		s.runner.Close()
+		s.runner = nil
+	}
+}
@@ -1,17 +1,13 @@
 package proxmox_lxc
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"io"
 	"net/url"
-	"os"
-	"path"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -20,150 +16,191 @@ import (
 )
 
 // stepConvertToTemplate takes the running VM configured in earlier steps, stops it, and
-// converts it into a Proxmox template.
+// exports it as a vzdump backup, converts it into a Proxmox template, or both,
+// depending on output_mode.
 //
 // It sets the template_id state which is used for Artifact lookup.
 type stepConvertToTemplate struct{}
 
-type templateConverter interface {
-	DeleteVm(*proxmox.VmRef) (string, error)
-	ShutdownVm(*proxmox.VmRef) (string, error)
-	CreateTemplate(*proxmox.VmRef) error
-	WaitForCompletion(taskResponse map[string]interface{}) (waitExitStatus string, err error)
-}
-
-var _ templateConverter = &proxmox.Client{}
-
 func (s *stepConvertToTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	c := state.Get("config").(*Config)
-	client := state.Get("proxmoxClient").(templateConverter)
+	tasks := state.Get("taskWaiter").(*taskWaiter)
 	vmRef := state.Get("vmRef").(*proxmox.VmRef)
-
-	ui.Say("Stopping LXC Container")
-	_, err := client.ShutdownVm(vmRef)
-	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, could not stop: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	vmPath := fmt.Sprintf("/nodes/%s/lxc/%d", vmRef.Node(), vmRef.VmId())
+
+	// The suspend and snapshot backup modes work on the running container,
+	// it is only stopped after the backup for them. Mount points can only be
+	// detached from a stopped container.
+	stopFirst := c.OutputMode == outputModeTemplate || c.BackupMode == backupModeStop || c.detachesMountPoints()
+	if stopFirst {
+		if err := shutdownContainer(ctx, ui, tasks, vmPath); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
-	ui.Say("Converting LXC Container to template")
-
-	tlsConf := &tls.Config{InsecureSkipVerify: true}
-	session, _ := proxmox.NewSession(c.proxmoxURL.String(), nil, "", tlsConf)
-	err = session.Login(c.Username, c.Password, "")
-	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, failed to create session: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
-	var body = url.Values{}
-	body.Add("mode", "stop")
-	body.Add("compress", "gzip")
-	body.Add("remove", "1")
-	body.Add("storage", c.TemplateStoragePool)
-	body.Add("vmid", strconv.Itoa(c.VMID))
-	var bodyEncode = bytes.NewBufferString(body.Encode()).Bytes()
-	resp, err := session.Post("/nodes/"+c.Node+"/vzdump", nil, nil, &bodyEncode)
-	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, failed to create backup: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	if c.OutputMode == outputModeVzdump || c.OutputMode == outputModeBoth {
+		ui.Say("Exporting LXC Container as vzdump backup")
+		err := s.exportBackup(ctx, ui, c, tasks)
+		if err != nil {
+			err := fmt.Errorf("Error converting VM to template, %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
-	taskResponse, err := proxmox.ResponseJSON(resp)
-	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, faield to parse backup response: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+
+	if !stopFirst {
+		if err := shutdownContainer(ctx, ui, tasks, vmPath); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
-	_, err = client.WaitForCompletion(taskResponse)
-	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, failed to wait process completion: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+
+	if c.OutputMode == outputModeVzdump {
+		ui.Say("Deleting LXC Container")
+		_, err := tasks.delete(ctx, vmPath)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error deleting VM. Please delete it manually: %s", err))
+		}
+		return multistep.ActionContinue
 	}
 
-	err = downloadBackup(ui, strings.Replace(c.Username, "@pam", "", 1), c.Password, c.proxmoxURL.Hostname(), 22, c.VMID, c.OutputPath)
+	ui.Say("Converting LXC Container to template")
+	_, err := tasks.post(ctx, vmPath+"/template", url.Values{})
 	if err != nil {
-		err := fmt.Errorf("Error converting VM to template, failed to donwload backup: %s", err)
+		err := fmt.Errorf("Error converting VM to template: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
-	ui.Say("Deleting LXC Container")
-	_, err = client.DeleteVm(vmRef)
-	if err != nil {
-		ui.Error(fmt.Sprintf("Error deleting VM. Please delete it manually: %s", err))
-	}
+	state.Put("template_id", vmRef.VmId())
 
 	return multistep.ActionContinue
 }
 
-func (s *stepConvertToTemplate) Cleanup(state multistep.StateBag) {}
-
-func downloadBackup(ui packersdk.Ui, apiUser string, apiPassword string, apiAddr string, apiPort int, vmId int, dstPath string) error {
-	config := &ssh.ClientConfig{
-		User: apiUser,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(apiPassword),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+// shutdownContainer shuts the container down and waits until it stopped.
+func shutdownContainer(ctx context.Context, ui packersdk.Ui, tasks *taskWaiter, vmPath string) error {
+	ui.Say("Stopping LXC Container")
+	if _, err := tasks.post(ctx, vmPath+"/status/shutdown", url.Values{}); err != nil {
+		return fmt.Errorf("Error converting VM to template, could not stop: %s", err)
 	}
+	return nil
+}
 
-	var sshAddr string = apiAddr + ":" + strconv.Itoa(apiPort)
-	ui.Say("Establishing SSH connection with [" + apiUser + "] at [" + sshAddr + "] for template file...")
-	client, _ := ssh.Dial("tcp", sshAddr, config)
-	defer client.Close()
+// exportBackup creates a vzdump backup of the container with backup_mode and
+// downloads it to output_path.
+func (s *stepConvertToTemplate) exportBackup(ctx context.Context, ui packersdk.Ui, c *Config, tasks *taskWaiter) error {
+	session := tasks.session
 
-	ui.Say("Establishing SFTP connection for template file...")
-	// open an SFTP session over an existing ssh connection.
-	ftpClient, err := sftp.NewClient(client)
-	if err != nil {
-		return err
+	var detach []string
+	for i, mp := range c.MountPoints {
+		if mp.Detach {
+			detach = append(detach, "mp"+strconv.Itoa(i))
+		}
 	}
-	defer ftpClient.Close()
-
-	ui.Say("Listing vzdump backup directory for template backup...")
-	dir := "/var/lib/vz/dump/"
-	files, err := ftpClient.ReadDir(dir)
-
-	var srcFilePath = ""
-	for _, file := range files {
-		match, err := regexp.MatchString(`vzdump-lxc-`+strconv.Itoa(vmId)+`-.*?\.tar\.gz`, file.Name())
-		if err == nil && match {
-			srcFilePath = path.Join(dir, file.Name())
+	if len(detach) > 0 {
+		ui.Say("Detaching mount points " + strings.Join(detach, ", "))
+		if err := detachMountPoints(ctx, tasks, c.Node, c.VMID, detach); err != nil {
+			return fmt.Errorf("failed to detach mount points: %s", err)
 		}
 	}
 
-	if srcFilePath == "" {
-		return fmt.Errorf("could not find backup file for LXC container %d", vmId)
+	var body = url.Values{}
+	body.Add("mode", c.BackupMode)
+	if c.BackupCompression == "none" {
+		body.Add("compress", "0")
+	} else {
+		body.Add("compress", c.BackupCompression)
 	}
-
-	ui.Say("Opening vzdump template backup " + srcFilePath + "...")
-	srcFile, err := ftpClient.Open(srcFilePath)
+	if c.BackupCompression == "zstd" && c.BackupZstdThreads > 0 {
+		body.Add("zstd", strconv.Itoa(c.BackupZstdThreads))
+	}
+	if c.BackupBandwidthLimit > 0 {
+		body.Add("bwlimit", strconv.Itoa(c.BackupBandwidthLimit))
+	}
+	body.Add("remove", "1")
+	body.Add("storage", c.BackupStorage)
+	body.Add("vmid", strconv.Itoa(c.VMID))
+	upid, err := tasks.post(ctx, "/nodes/"+c.Node+"/vzdump", body)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create backup: %s", err)
 	}
-	defer srcFile.Close()
 
-	ui.Say("Creating local template file " + dstPath + "...")
-	dstFile, err := os.Create(dstPath)
+	ui.Say("Locating vzdump template backup on storage " + c.BackupStorage + "...")
+	volume, err := locateBackup(session, c.Node, c.BackupStorage, c.VMID, upid, backupExtensions[c.BackupCompression])
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to locate backup: %s", err)
 	}
-	defer dstFile.Close()
 
-	ui.Say("Transferring vzdump template backup file to local path...")
-	// write to file
-	if _, err := dstFile.ReadFrom(srcFile); err != nil {
-		return err
+	err = downloadBackup(ctx, ui, c, volume, c.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to donwload backup: %s", err)
 	}
-
 	return nil
 }
+
+func (s *stepConvertToTemplate) Cleanup(state multistep.StateBag) {}
+
+// detachMountPoints removes the given mount points from the container config.
+// Volumes on a storage are kept as unused disks of the container.
+func detachMountPoints(ctx context.Context, tasks *taskWaiter, node string, vmId int, mountPoints []string) error {
+	var body = url.Values{}
+	body.Add("delete", strings.Join(mountPoints, ","))
+	_, err := tasks.put(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmId), body)
+	return err
+}
+
+// downloadBackup transfers the backup volume from the node over SFTP and
+// checksums it over SSH. Every transfer attempt uses a new SSH connection, as
+// a broken transfer usually means the previous one is gone.
+func downloadBackup(ctx context.Context, ui packersdk.Ui, c *Config, volume *backupVolume, dstPath string) error {
+	ui.Say("Transferring vzdump template backup " + volume.Path + " to " + dstPath + " over SFTP...")
+	return downloadResumable(ctx, ui, volume, dstPath, func(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+		client, err := dialNode(c)
+		if err != nil {
+			return nil, 0, err
+		}
+		// open an SFTP session over the ssh connection.
+		ftpClient, err := sftp.NewClient(client)
+		if err != nil {
+			client.Close()
+			return nil, 0, err
+		}
+		srcFile, err := ftpClient.Open(volume.Path)
+		if err != nil {
+			ftpClient.Close()
+			client.Close()
+			return nil, 0, err
+		}
+		reader := &sftpReader{File: srcFile, sftp: ftpClient, ssh: client}
+		if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+			reader.Close()
+			return nil, 0, err
+		}
+		return reader, offset, nil
+	}, func() (nodeRunner, error) {
+		client, err := dialNode(c)
+		if err != nil {
+			return nil, err
+		}
+		return &sshNodeRunner{client: client}, nil
+	})
+}
+
+// sftpReader is a remote file that closes its SFTP session and SSH
+// connection with it.
+type sftpReader struct {
+	*sftp.File
+	sftp *sftp.Client
+	ssh  *ssh.Client
+}
+
+func (r *sftpReader) Close() error {
+	r.File.Close()
+	r.sftp.Close()
+	return r.ssh.Close()
+}
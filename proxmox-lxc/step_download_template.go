@@ -0,0 +1,146 @@
+package proxmox_lxc
+
+import (
+	"context"
+	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"net/url"
+	"sort"
+)
+
+// stepDownloadTemplate makes sure the OS template exists in the vztmpl content
+// of template_storage_pool. When it is missing, it is fetched by the node's
+// download-url API, either from template_url or from the appliance index entry
+// named by template_appliance.
+type stepDownloadTemplate struct{}
+
+// applianceInfo is a single entry of the node's appliance index.
+type applianceInfo struct {
+	Template  string `json:"template"`
+	Package   string `json:"package"`
+	Type      string `json:"type"`
+	Location  string `json:"location"`
+	SHA512Sum string `json:"sha512sum"`
+	MD5Sum    string `json:"md5sum"`
+}
+
+func (s *stepDownloadTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+	tasks := state.Get("taskWaiter").(*taskWaiter)
+
+	if c.TemplateAppliance == "" && c.TemplateURL == "" {
+		return multistep.ActionContinue
+	}
+
+	session, err := newSession(c)
+	if err != nil {
+		err := fmt.Errorf("Error downloading template, failed to create session: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	downloadURL, checksum := c.TemplateURL, c.TemplateChecksum
+	if c.TemplateAppliance != "" {
+		appliance, err := findAppliance(session, c.Node, c.TemplateAppliance)
+		if err != nil {
+			err := fmt.Errorf("Error downloading template: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if c.TemplateFile == "" {
+			c.TemplateFile = appliance.Template
+		}
+		downloadURL = appliance.Location
+		switch {
+		case appliance.SHA512Sum != "":
+			checksum = "sha512:" + appliance.SHA512Sum
+		case appliance.MD5Sum != "":
+			checksum = "md5:" + appliance.MD5Sum
+		}
+	}
+
+	volid := c.TemplateStoragePool + ":vztmpl/" + c.TemplateFile
+	exists, err := templateExists(session, c.Node, c.TemplateStoragePool, volid)
+	if err != nil {
+		err := fmt.Errorf("Error downloading template, failed to list storage %s: %s", c.TemplateStoragePool, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if exists {
+		ui.Say("Using existing template " + volid)
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Downloading template " + volid + " from " + downloadURL)
+	var body = url.Values{}
+	body.Add("content", "vztmpl")
+	body.Add("filename", c.TemplateFile)
+	body.Add("url", downloadURL)
+	if algorithm, value := splitChecksum(checksum); value != "" {
+		body.Add("checksum-algorithm", algorithm)
+		body.Add("checksum", value)
+	}
+	_, err = tasks.post(ctx, "/nodes/"+c.Node+"/storage/"+c.TemplateStoragePool+"/download-url", body)
+	if err != nil {
+		err := fmt.Errorf("Error downloading template: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepDownloadTemplate) Cleanup(state multistep.StateBag) {}
+
+// templateExists reports whether volid is part of the vztmpl content of the storage.
+func templateExists(session *proxmox.Session, node string, storage string, volid string) (bool, error) {
+	params := url.Values{}
+	params.Add("content", "vztmpl")
+
+	var volumes []struct {
+		VolID string `json:"volid"`
+	}
+	if err := getData(session, "/nodes/"+node+"/storage/"+storage+"/content", &params, &volumes); err != nil {
+		return false, err
+	}
+	for _, volume := range volumes {
+		if volume.VolID == volid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findAppliance looks up a container template in the node's appliance index,
+// by package name (e.g. debian-12-standard) or full template file name. When
+// several versions of a package are listed the newest is used.
+func findAppliance(session *proxmox.Session, node string, name string) (*applianceInfo, error) {
+	var appliances []applianceInfo
+	if err := getData(session, "/nodes/"+node+"/aplinfo", nil, &appliances); err != nil {
+		return nil, fmt.Errorf("failed to read appliance index: %s", err)
+	}
+
+	var matches []applianceInfo
+	for _, appliance := range appliances {
+		if appliance.Type != "" && appliance.Type != "lxc" {
+			continue
+		}
+		if appliance.Package == name || appliance.Template == name {
+			matches = append(matches, appliance)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("appliance %s not found in the appliance index of node %s, try running pveam update", name, node)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Template < matches[j].Template
+	})
+	return &matches[len(matches)-1], nil
+}
@@ -0,0 +1,285 @@
+package proxmox_lxc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stepSelectNode picks the node to build on when node is "auto" or
+// node_candidates is set. Online nodes that have the required storages, the
+// OS template and enough free memory are ranked by free memory and CPU load,
+// or by when they were last used for a build if node_least_recently_used is
+// set.
+//
+// It sets the node of the config, which every later step uses, and the
+// address SSH connections to that node go to: node_ssh_host if it is set,
+// the cluster address of an automatically selected node, or else the
+// proxmox_url host.
+type stepSelectNode struct{}
+
+// nodeResource is a node entry of the cluster resources.
+type nodeResource struct {
+	Node   string  `json:"node"`
+	Status string  `json:"status"`
+	CPU    float64 `json:"cpu"`
+	Mem    int64   `json:"mem"`
+	MaxMem int64   `json:"maxmem"`
+}
+
+// storageResource is a storage entry of the cluster resources.
+type storageResource struct {
+	Node    string `json:"node"`
+	Storage string `json:"storage"`
+	Status  string `json:"status"`
+}
+
+// nodeUsageFile records in the Packer cache when each node was last selected.
+const nodeUsageFile = "proxmox-lxc-node-usage.json"
+
+func (s *stepSelectNode) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if c.Node != nodeAuto {
+		if c.NodeSSHHost != "" {
+			if err := s.renderNodeSSHHost(c); err != nil {
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Selecting Proxmox node")
+	session, err := newSession(c)
+	if err != nil {
+		err := fmt.Errorf("Error selecting node, failed to create session: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	candidates, err := eligibleNodes(session, c)
+	if err != nil {
+		err := fmt.Errorf("Error selecting node: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Rank by the free share of the node, after that by when it was used
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return nodeScore(candidates[i]) > nodeScore(candidates[j])
+	})
+	usage := readNodeUsage()
+	if c.NodeLeastRecentlyUsed {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return usage[candidates[i].Node].Before(usage[candidates[j].Node])
+		})
+	}
+	for _, node := range candidates {
+		log.Printf("Node %s: cpu %.2f, memory %d/%d, last used %s", node.Node, node.CPU, node.Mem, node.MaxMem, usage[node.Node])
+	}
+
+	c.Node = candidates[0].Node
+	usage[c.Node] = time.Now()
+	writeNodeUsage(usage)
+
+	// Commands and downloads over SSH go to the selected node instead of
+	// the API host
+	if c.NodeSSHHost != "" {
+		err = s.renderNodeSSHHost(c)
+	} else {
+		c.nodeAddress, err = nodeAddress(session, c.Node)
+	}
+	if err != nil {
+		err := fmt.Errorf("Error selecting node: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Using node " + c.Node)
+	return multistep.ActionContinue
+}
+
+func (s *stepSelectNode) Cleanup(state multistep.StateBag) {}
+
+// renderNodeSSHHost sets the node address from node_ssh_host, which may
+// refer to the node as {{ .Node }}.
+func (s *stepSelectNode) renderNodeSSHHost(c *Config) error {
+	ctx := c.ctx
+	ctx.Data = &nodeSSHHostData{Node: c.Node}
+	address, err := interpolate.Render(c.NodeSSHHost, &ctx)
+	if err != nil {
+		return fmt.Errorf("Error rendering node_ssh_host: %s", err)
+	}
+	c.nodeAddress = address
+	return nil
+}
+
+// nodeSSHHostData is the template data of node_ssh_host.
+type nodeSSHHostData struct {
+	Node string
+}
+
+// eligibleNodes returns the online candidate nodes that can run the build.
+func eligibleNodes(session *proxmox.Session, c *Config) ([]nodeResource, error) {
+	params := url.Values{}
+	params.Add("type", "node")
+	var nodes []nodeResource
+	if err := getData(session, "/cluster/resources", &params, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to read cluster nodes: %s", err)
+	}
+
+	params.Set("type", "storage")
+	var storages []storageResource
+	if err := getData(session, "/cluster/resources", &params, &storages); err != nil {
+		return nil, fmt.Errorf("failed to read cluster storages: %s", err)
+	}
+	available := map[string]bool{}
+	for _, storage := range storages {
+		if storage.Status == "available" {
+			available[storage.Node+"/"+storage.Storage] = true
+		}
+	}
+
+	var eligible []nodeResource
+	var rejected []string
+	for _, node := range nodes {
+		if len(c.NodeCandidates) > 0 && !contains(c.NodeCandidates, node.Node) {
+			continue
+		}
+		reason := ""
+		if node.Status != "online" {
+			reason = "not online"
+		} else if node.MaxMem-node.Mem < int64(c.Memory)*1024*1024 {
+			reason = fmt.Sprintf("less than %d MB of free memory", c.Memory)
+		}
+		for _, storage := range c.requiredStorages() {
+			if reason == "" && !available[node.Node+"/"+storage] {
+				reason = "storage " + storage + " is not available"
+			}
+		}
+		if reason == "" && c.requiresExistingTemplate() {
+			volid := c.TemplateStoragePool + ":vztmpl/" + c.TemplateFile
+			exists, err := templateExists(session, node.Node, c.TemplateStoragePool, volid)
+			if err != nil {
+				reason = "failed to list templates: " + err.Error()
+			} else if !exists {
+				reason = "template " + volid + " does not exist"
+			}
+		}
+		if reason != "" {
+			rejected = append(rejected, node.Node+": "+reason)
+			continue
+		}
+		eligible = append(eligible, node)
+	}
+	if len(eligible) == 0 {
+		if len(rejected) == 0 {
+			return nil, fmt.Errorf("none of the node candidates is part of the cluster")
+		}
+		return nil, fmt.Errorf("no node can run the build (%s)", strings.Join(rejected, "; "))
+	}
+	return eligible, nil
+}
+
+// nodeAddress returns the cluster address of the node.
+func nodeAddress(session *proxmox.Session, node string) (string, error) {
+	var members []struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+		IP   string `json:"ip"`
+	}
+	if err := getData(session, "/cluster/status", nil, &members); err != nil {
+		return "", fmt.Errorf("failed to read cluster status: %s", err)
+	}
+	for _, member := range members {
+		if member.Type == "node" && member.Name == node && member.IP != "" {
+			return member.IP, nil
+		}
+	}
+	return "", fmt.Errorf("no address of node %s in cluster status", node)
+}
+
+// nodeScore weighs the free memory of a node by its idle CPU share.
+func nodeScore(node nodeResource) float64 {
+	return float64(node.MaxMem-node.Mem) * (1 - node.CPU)
+}
+
+// requiredStorages returns the storages the build uses on its node.
+func (c *Config) requiredStorages() []string {
+	var storages []string
+	if !c.cloning() {
+		storages = append(storages, c.TemplateStoragePool)
+	}
+	if c.FSStorage != "" {
+		storages = append(storages, c.FSStorage)
+	}
+	if c.OutputMode != outputModeTemplate {
+		storages = append(storages, c.BackupStorage)
+	}
+	for _, mp := range c.MountPoints {
+		if mp.Storage != "" {
+			storages = append(storages, mp.Storage)
+		}
+	}
+	return storages
+}
+
+// requiresExistingTemplate reports whether template_file has to be present
+// on the node already, because the builder has no source to fetch it from.
+func (c *Config) requiresExistingTemplate() bool {
+	return !c.cloning() && c.TemplateAppliance == "" && c.TemplateURL == "" && c.TemplateLocalFile == ""
+}
+
+// readNodeUsage loads when each node was last selected. Missing or unreadable
+// records count as never used.
+func readNodeUsage() map[string]time.Time {
+	usage := map[string]time.Time{}
+	path, err := packersdk.CachePath(nodeUsageFile)
+	if err != nil {
+		return usage
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return usage
+	}
+	if err := json.Unmarshal(content, &usage); err != nil {
+		log.Printf("Ignoring node usage records in %s: %s", path, err)
+	}
+	return usage
+}
+
+// writeNodeUsage stores the node usage records, replacing the file so
+// parallel builds never read a partial write.
+func writeNodeUsage(usage map[string]time.Time) {
+	path, err := packersdk.CachePath(nodeUsageFile)
+	if err != nil {
+		log.Printf("Failed to record node usage: %s", err)
+		return
+	}
+	content, _ := json.Marshal(usage)
+	tmp := fmt.Sprintf("%s.%d", path, os.Getpid())
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		log.Printf("Failed to record node usage: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Failed to record node usage: %s", err)
+	}
+}
@@ -1,15 +1,21 @@
 package proxmox_lxc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // stepStartContainer takes the given configuration and starts a VM on the given Proxmox node.
@@ -18,85 +24,93 @@ import (
 // in API calls.
 type stepStartContainer struct{}
 
+// vmidAttempts is how often creation is tried with an automatically
+// allocated VM ID before giving up.
+const vmidAttempts = 5
+
 func (s *stepStartContainer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	client := state.Get("proxmoxClient").(*proxmox.Client)
+	tasks := state.Get("taskWaiter").(*taskWaiter)
 	c := state.Get("config").(*Config)
 
-	ui.Say("Creating LXC Container")
-
 	config := proxmox.NewConfigLxc()
 	config.Ostemplate = c.TemplateStoragePool + ":vztmpl/" + c.TemplateFile
 	config.Force = true
 	config.Unprivileged = c.Unprivileged
-	config.Password = c.Comm.SSHPassword
-	config.Start = true
+	config.Memory = c.Memory
+	if c.Swap != nil {
+		config.Swap = *c.Swap
+	}
+	config.Cores = c.Cores
+	config.CPULimit = c.CPULimit
+	config.CPUUnits = c.CPUUnits
+	config.Arch = c.Arch
+	config.OsType = c.OSType
+	config.Hostname = c.Hostname
+	config.Nameserver = c.Nameserver
+	config.SearchDomain = c.SearchDomain
+	config.Tags = strings.Join(c.Tags, ";")
+	config.Description = c.Description
+	config.CMode = c.ConsoleMode
+	if c.TTY != nil {
+		config.Tty = *c.TTY
+	}
+	config.Password = c.ProvisionPassword
+	// Mount points are added before the container is started
+	config.Start = false
 	config.Storage = c.TemplateStoragePool
 	config.RootFs = proxmox.QemuDevice{
 		"storage": c.FSStorage,
 		"size":    strconv.Itoa(c.FSSize) + "G",
 	}
-	keyPath, err := pathing.ExpandUser(c.ProvisionPublicKeyPath)
-	if err != nil {
-		err := fmt.Errorf("Error uploading SSH key: %s", err)
-		ui.Message(err.Error())
-		return multistep.ActionHalt
-	}
-	content, err := ioutil.ReadFile(keyPath)
-	if err != nil {
-		err := fmt.Errorf("Error uploading SSH key: %s", err)
-		ui.Message(err.Error())
-		return multistep.ActionHalt
-	}
+	if c.ProvisionPublicKeyPath != "" {
+		keyPath, err := pathing.ExpandUser(c.ProvisionPublicKeyPath)
+		if err != nil {
+			err := fmt.Errorf("Error uploading SSH key: %s", err)
+			ui.Message(err.Error())
+			return multistep.ActionHalt
+		}
+		content, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			err := fmt.Errorf("Error uploading SSH key: %s", err)
+			ui.Message(err.Error())
+			return multistep.ActionHalt
+		}
 
-	config.SSHPublicKeys = string(content)
-	config.Networks = proxmox.QemuDevices{
-		0: {
-			"bridge":   "vmbr0",
-			"name":     "eth0",
-			"ip":       "dhcp",
-			"firewall": 0,
-			"hwaddr":   c.ProvisionMac,
-		},
+		config.SSHPublicKeys = string(content)
+	} else if len(c.Comm.SSHPublicKey) > 0 && c.Comm.SSHTemporaryKeyPairName != "" {
+		// The comment lets StepCleanupTempKeys find the key again
+		config.SSHPublicKeys = strings.TrimSpace(string(c.Comm.SSHPublicKey)) + " " + c.Comm.SSHTemporaryKeyPairName
+	}
+	config.Networks = proxmox.QemuDevices{}
+	for i, nic := range c.NICs {
+		config.Networks[i] = nic.device()
 	}
 
-	if c.Unprivileged {
-		config.Features = proxmox.QemuDevice{
-			"keyctl":  1,
-			"nesting": 1,
-		}
+	if c.Features.enabled() {
+		config.Features = c.Features.device()
 	}
 
-	if c.VMID == 0 {
-		ui.Say("No VM ID given, getting next free from Proxmox")
-		for n := 0; n < 5; n++ {
-			id, err := proxmox.MaxVmId(client)
-			if err != nil {
-				log.Printf("Error getting max used VM ID: %v (attempt %d/5)", err, n+1)
-				continue
-			}
-			c.VMID = id + 1
-			break
-		}
-		if c.VMID == 0 {
-			err := fmt.Errorf("Failed to get free VM ID")
+	var source *proxmox.VmRef
+	if c.cloning() {
+		var err error
+		source, err = cloneSource(client, c)
+		if err != nil {
+			err := fmt.Errorf("Error finding container to clone: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
 	}
-	vmRef := proxmox.NewVmRef(c.VMID)
-	vmRef.SetNode(c.Node)
-	if c.Pool != "" {
-		vmRef.SetPool(c.Pool)
-	}
 
-	err = config.CreateLxc(vmRef, client)
+	vmRef, err := s.createContainer(ctx, ui, client, tasks, c, config, source)
 	if err != nil {
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	vmRef.SetVmType("lxc")
 
 	// Store the vm id for later
 	state.Put("vmRef", vmRef)
@@ -104,8 +118,61 @@ func (s *stepStartContainer) Run(ctx context.Context, state multistep.StateBag)
 	// instance id inside of the provisioners, used in step_provision.
 	state.Put("instance_id", vmRef)
 
+	if source != nil {
+		err := configureClone(ctx, tasks, c, vmRef)
+		if err != nil {
+			err := fmt.Errorf("Error configuring cloned container: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else {
+		// proxmox-api-go can not create containers with these options
+		params := mountPointParams(c)
+		if c.Timezone != "" {
+			params["timezone"] = c.Timezone
+		}
+		if len(params) > 0 {
+			err := tasks.setLxcConfig(ctx, vmRef, params)
+			if err != nil {
+				err := fmt.Errorf("Error configuring LXC Container: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	if len(c.Devices) > 0 {
+		ui.Say("Adding devices to LXC Container")
+		err := tasks.setLxcConfig(ctx, vmRef, deviceParams(c))
+		if err != nil && permissionDenied(err) {
+			err = fmt.Errorf("%s (only root@pam may pass devices through to containers)", err)
+		}
+		if err != nil {
+			err := fmt.Errorf("Error adding devices: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if len(c.RawConfig) > 0 {
+		ui.Say("Adding raw LXC configuration on node " + c.Node)
+		err := appendRawConfig(ctx, c, c.VMID)
+		if err != nil {
+			err := fmt.Errorf("Error adding raw LXC configuration: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("VMID", c.VMID)
+
 	ui.Say("Starting LXC Container")
-	_, err = client.StartVm(vmRef)
+	_, err = tasks.post(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/status/start", c.Node, c.VMID), url.Values{})
 	if err != nil {
 		err := fmt.Errorf("Error starting VM: %s", err)
 		state.Put("error", err)
@@ -113,15 +180,92 @@ func (s *stepStartContainer) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
+	// A clone keeps the credentials of its source, install the ones
+	// the communicator was configured with
+	if source != nil && c.Comm.Type == "ssh" {
+		ui.Say("Installing provisioning credentials in LXC Container")
+		err := installCredentials(ctx, c, c.VMID, config.SSHPublicKeys)
+		if err != nil {
+			err := fmt.Errorf("Error installing provisioning credentials: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	return multistep.ActionContinue
 }
 
-type startedVMCleaner interface {
-	StopVm(*proxmox.VmRef) (string, error)
-	DeleteVm(*proxmox.VmRef) (string, error)
+// createContainer creates or clones the container. Without a configured vmid
+// the next free ID in vmid_range is used, and when a parallel build takes
+// that ID first creation is retried with the next one.
+func (s *stepStartContainer) createContainer(ctx context.Context, ui packersdk.Ui, client *proxmox.Client, tasks *taskWaiter, c *Config, config proxmox.ConfigLxc, source *proxmox.VmRef) (*proxmox.VmRef, error) {
+	autoVMID := c.VMID == 0
+	nextVMID := c.vmidMin
+	for attempt := 1; ; attempt++ {
+		if autoVMID {
+			id, err := nextFreeVMID(tasks.session, nextVMID, c.vmidMax)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get free VM ID: %s", err)
+			}
+			log.Printf("Using free VM ID %d", id)
+			c.VMID = id
+		}
+		vmRef := proxmox.NewVmRef(c.VMID)
+		vmRef.SetNode(c.Node)
+		if c.Pool != "" {
+			vmRef.SetPool(c.Pool)
+		}
+
+		var err error
+		if source != nil {
+			ui.Say(fmt.Sprintf("Cloning LXC Container %d to %d", source.VmId(), c.VMID))
+			if err = cloneContainer(ctx, tasks, c, source, vmRef); err != nil {
+				err = fmt.Errorf("Error cloning container: %s", err)
+			}
+		} else {
+			ui.Say(fmt.Sprintf("Creating LXC Container %d", c.VMID))
+			if _, err = tasks.post(ctx, "/nodes/"+c.Node+"/lxc", createParams(config, c.VMID)); err != nil {
+				err = fmt.Errorf("Error creating LXC Container: %s", err)
+			}
+		}
+		if err == nil {
+			return vmRef, nil
+		}
+
+		if !autoVMID || attempt == vmidAttempts || !strings.Contains(err.Error(), "already exists") {
+			return nil, err
+		}
+		delay := time.Duration(1<<uint(attempt-1)) * time.Second
+		ui.Say(fmt.Sprintf("VM ID %d was taken by another build, retrying in %s", c.VMID, delay))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		nextVMID = c.VMID + 1
+	}
 }
 
-var _ startedVMCleaner = &proxmox.Client{}
+// nextFreeVMID returns the first VM ID between min and max that the cluster
+// reports as unused. proxmox.Client.GetNextID is not used, as it keeps probing
+// past max.
+func nextFreeVMID(session *proxmox.Session, min int, max int) (int, error) {
+	for id := min; id <= max; id++ {
+		params := url.Values{}
+		params.Add("vmid", strconv.Itoa(id))
+		var next json.Number
+		err := getData(session, "/cluster/nextid", &params, &next)
+		if err == nil {
+			return id, nil
+		}
+		// Proxmox fails the parameter verification for IDs in use
+		if !strings.HasPrefix(err.Error(), "400 ") {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("no free VM ID left in vmid_range %d-%d", min, max)
+}
 
 func (s *stepStartContainer) Cleanup(state multistep.StateBag) {
 	vmRefUntyped, ok := state.GetOk("vmRef")
@@ -137,21 +281,153 @@ func (s *stepStartContainer) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	client := state.Get("proxmoxClient").(startedVMCleaner)
+	tasks := state.Get("taskWaiter").(*taskWaiter)
 	ui := state.Get("ui").(packersdk.Ui)
+	// The build context may already be cancelled
+	ctx := context.Background()
+	vmPath := fmt.Sprintf("/nodes/%s/lxc/%d", vmRef.Node(), vmRef.VmId())
 
 	// Destroy the server we just created
-	ui.Say("Stopping LXC Container")
-	_, err := client.StopVm(vmRef)
-	if err != nil {
-		ui.Error(fmt.Sprintf("Error stopping VM. Please stop and delete it manually: %s", err))
-		return
+	var current struct {
+		Status string `json:"status"`
+	}
+	if err := getData(tasks.session, vmPath+"/status/current", nil, &current); err != nil || current.Status != "stopped" {
+		ui.Say("Stopping LXC Container")
+		_, err := tasks.post(ctx, vmPath+"/status/stop", url.Values{})
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error stopping VM. Please stop and delete it manually: %s", err))
+			return
+		}
 	}
 
 	ui.Say("Deleting LXC Container")
-	_, err = client.DeleteVm(vmRef)
+	_, err := tasks.delete(ctx, vmPath)
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error deleting VM. Please delete it manually: %s", err))
 		return
 	}
 }
+
+// device converts the adapter into the netN option of the LXC config. Unset
+// values are left out so Proxmox applies its own defaults.
+func (nic nicConfig) device() proxmox.QemuDevice {
+	device := proxmox.QemuDevice{
+		"name":     nic.Name,
+		"bridge":   nic.Bridge,
+		"firewall": nic.Firewall,
+		"ip":       nic.IP,
+		"gw":       nic.Gateway,
+		"ip6":      nic.IP6,
+		"gw6":      nic.Gateway6,
+		"hwaddr":   nic.MACAddress,
+		"tag":      nic.VLANTag,
+		"mtu":      nic.MTU,
+	}
+	if nic.Rate > 0 {
+		device["rate"] = strconv.FormatFloat(nic.Rate, 'f', -1, 64)
+	}
+	return device
+}
+
+// mountPointParams returns the mpN options for the configured mount points.
+// Volumes on a storage are allocated by Proxmox, host paths are bind mounted.
+func mountPointParams(c *Config) map[string]interface{} {
+	params := map[string]interface{}{}
+	for i, mp := range c.MountPoints {
+		params["mp"+strconv.Itoa(i)] = mp.device()
+	}
+	return params
+}
+
+// device converts the mount point into the mpN option of the LXC config.
+func (mp mountPointConfig) device() string {
+	volume := mp.HostPath
+	if volume == "" {
+		volume = mp.Storage + ":" + strconv.Itoa(mp.Size)
+	}
+	options := proxmox.QemuDevice{
+		"mp":     mp.Path,
+		"ro":     mp.ReadOnly,
+		"backup": mp.Backup,
+		"quota":  mp.Quota,
+		"acl":    mp.ACL,
+	}
+	if len(mp.MountOptions) > 0 {
+		options["mountoptions"] = strings.Join(mp.MountOptions, ";")
+	}
+	return volume + "," + formatDevice(options)
+}
+
+// createParams converts the container config into the parameters of the
+// create request, formatted the way proxmox.ConfigLxc.CreateLxc does.
+func createParams(config proxmox.ConfigLxc, vmId int) url.Values {
+	var params map[string]interface{}
+	raw, _ := json.Marshal(&config)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// Keep large numbers out of exponent notation
+	decoder.UseNumber()
+	decoder.Decode(&params)
+
+	for _, key := range []string{"features", "rootfs", "networks", "mountpoints", "unused", "hastate", "hagroup"} {
+		delete(params, key)
+	}
+	params["vmid"] = vmId
+	params["features"] = formatDevice(config.Features)
+	if config.RootFs != nil {
+		params["rootfs"] = proxmox.FormatDiskParam(config.RootFs)
+	}
+	for i, nic := range config.Networks {
+		params["net"+strconv.Itoa(i)] = formatDevice(nic)
+	}
+	return proxmox.ParamsToValues(params)
+}
+
+// permissionDenied reports whether the API refused a request for lack of
+// privileges. Proxmox answers those with status 403, or for options only root
+// may set, with an error naming root.
+func permissionDenied(err error) bool {
+	return strings.HasPrefix(err.Error(), "403 ") || strings.Contains(err.Error(), "only root")
+}
+
+// deviceParams returns the devN options for the configured devices.
+func deviceParams(c *Config) map[string]interface{} {
+	params := map[string]interface{}{}
+	for i, dev := range c.Devices {
+		options := proxmox.QemuDevice{
+			"mode": dev.Mode,
+			"uid":  dev.UID,
+			"gid":  dev.GID,
+		}
+		device := dev.Path
+		if formatted := formatDevice(options); formatted != "" {
+			device += "," + formatted
+		}
+		params["dev"+strconv.Itoa(i)] = device
+	}
+	return params
+}
+
+// appendRawConfig appends the lxc_raw_config lines to the container config
+// file. The API does not accept lxc.* keys, so the file is edited on the node
+// through the cluster file system.
+func appendRawConfig(ctx context.Context, c *Config, vmId int) error {
+	runner, err := newNodeRunner(c)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+
+	var lines strings.Builder
+	for _, line := range c.RawConfig {
+		key, value := splitRawConfig(line)
+		lines.WriteString(key + ": " + value + "\n")
+	}
+
+	conf := fmt.Sprintf("/etc/pve/nodes/%s/lxc/%d.conf", c.Node, vmId)
+	var stderr bytes.Buffer
+	command := fmt.Sprintf("test -w %s && cat >> %s", shellQuote(conf), shellQuote(conf))
+	if err := runner.Run(ctx, command, strings.NewReader(lines.String()), nil, &stderr); err != nil {
+		return fmt.Errorf("can not write %s, editing it requires root on the node: %s %s", conf, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package proxmox_lxc
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stepUploadTemplate uploads template_local_file to the vztmpl content of
+// template_storage_pool. The uploaded file name carries a digest of the
+// content, so an identical upload from an earlier build is reused.
+//
+// It sets template_file to the uploaded file name.
+type stepUploadTemplate struct {
+	uploadedVolume string
+}
+
+func (s *stepUploadTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+	tasks := state.Get("taskWaiter").(*taskWaiter)
+
+	if c.TemplateLocalFile == "" {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Verifying template " + c.TemplateLocalFile)
+	digest, err := verifyTemplateChecksum(c.TemplateLocalFile, c.TemplateChecksum)
+	if err != nil {
+		err := fmt.Errorf("Error uploading template: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	base := filepath.Base(c.TemplateLocalFile)
+	ext := templateExtension(base)
+	c.TemplateFile = strings.TrimSuffix(base, ext) + "-" + digest[:12] + ext
+	volid := c.TemplateStoragePool + ":vztmpl/" + c.TemplateFile
+
+	session, err := newSession(c)
+	if err != nil {
+		err := fmt.Errorf("Error uploading template, failed to create session: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	exists, err := templateExists(session, c.Node, c.TemplateStoragePool, volid)
+	if err != nil {
+		err := fmt.Errorf("Error uploading template, failed to list storage %s: %s", c.TemplateStoragePool, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if exists {
+		ui.Say("Using previously uploaded template " + volid)
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Uploading template " + c.TemplateLocalFile + " to " + volid)
+	f, err := os.Open(c.TemplateLocalFile)
+	if err != nil {
+		err := fmt.Errorf("Error uploading template: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer f.Close()
+
+	_, err = tasks.upload(ctx, c.Node, c.TemplateStoragePool, "vztmpl", c.TemplateFile, f)
+	if err != nil {
+		err := fmt.Errorf("Error uploading template: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.uploadedVolume = volid
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadTemplate) Cleanup(state multistep.StateBag) {
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.uploadedVolume == "" || !c.RemoveUploaded {
+		return
+	}
+
+	ui.Say("Removing uploaded template " + s.uploadedVolume)
+	session, err := newSession(c)
+	if err == nil {
+		_, err = session.Delete("/nodes/"+c.Node+"/storage/"+c.TemplateStoragePool+"/content/"+url.PathEscape(s.uploadedVolume), nil, nil)
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error removing uploaded template. Please delete it manually: %s", err))
+	}
+}
+
+// verifyTemplateChecksum checks the file against an optional algorithm:value
+// checksum and returns the hex encoded SHA-256 digest of the file.
+func verifyTemplateChecksum(file string, checksum string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	digest := sha256.New()
+	writers := []io.Writer{digest}
+
+	algorithm, expected := splitChecksum(checksum)
+	var verify hash.Hash
+	switch algorithm {
+	case "md5":
+		verify = md5.New()
+	case "sha1":
+		verify = sha1.New()
+	case "sha224":
+		verify = sha256.New224()
+	case "sha256":
+		verify = sha256.New()
+	case "sha384":
+		verify = sha512.New384()
+	case "sha512":
+		verify = sha512.New()
+	}
+	if verify != nil {
+		writers = append(writers, verify)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return "", err
+	}
+	if verify != nil {
+		if actual := hex.EncodeToString(verify.Sum(nil)); !strings.EqualFold(actual, expected) {
+			return "", fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s", algorithm, file, expected, actual)
+		}
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
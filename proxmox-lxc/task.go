@@ -0,0 +1,217 @@
+package proxmox_lxc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// taskLogTail is how many task log lines are included in the error of a
+// failed task.
+const taskLogTail = 10
+
+// taskWaiter starts Proxmox tasks and follows them to completion, forwarding
+// their log lines to the UI. Unlike proxmox.Client.WaitForCompletion it treats
+// a task that finished with an error as failed, and it stops the task when the
+// build is cancelled.
+type taskWaiter struct {
+	session *proxmox.Session
+	ui      packersdk.Ui
+	timeout time.Duration
+}
+
+func newTaskWaiter(c *Config, ui packersdk.Ui) (*taskWaiter, error) {
+	session, err := newSession(c)
+	if err != nil {
+		return nil, err
+	}
+	return &taskWaiter{
+		session: session,
+		ui:      ui,
+		timeout: c.TaskTimeout,
+	}, nil
+}
+
+// post starts a task with a POST request and waits for it.
+func (w *taskWaiter) post(ctx context.Context, path string, body url.Values) (string, error) {
+	encoded := bytes.NewBufferString(body.Encode()).Bytes()
+	resp, err := w.session.Post(path, nil, nil, &encoded)
+	if err != nil {
+		return "", err
+	}
+	return w.wait(ctx, resp)
+}
+
+// put starts a task with a PUT request and waits for it.
+func (w *taskWaiter) put(ctx context.Context, path string, body url.Values) (string, error) {
+	encoded := bytes.NewBufferString(body.Encode()).Bytes()
+	resp, err := w.session.Put(path, nil, nil, &encoded)
+	if err != nil {
+		return "", err
+	}
+	return w.wait(ctx, resp)
+}
+
+// delete starts a task with a DELETE request and waits for it.
+func (w *taskWaiter) delete(ctx context.Context, path string) (string, error) {
+	resp, err := w.session.Delete(path, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return w.wait(ctx, resp)
+}
+
+// setLxcConfig updates the config of a container and waits for the task of
+// changes Proxmox applies in the background.
+func (w *taskWaiter) setLxcConfig(ctx context.Context, vmRef *proxmox.VmRef, params map[string]interface{}) error {
+	_, err := w.put(ctx, fmt.Sprintf("/nodes/%s/lxc/%d/config", vmRef.Node(), vmRef.VmId()), proxmox.ParamsToValues(params))
+	return err
+}
+
+// upload streams file to the storage and waits for the task that imports it.
+// The multipart body is assembled around the file so its length is known
+// without reading the file into memory.
+func (w *taskWaiter) upload(ctx context.Context, node string, storage string, contentType string, filename string, file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	var head bytes.Buffer
+	mw := multipart.NewWriter(&head)
+	if err := mw.WriteField("content", contentType); err != nil {
+		return "", err
+	}
+	if _, err := mw.CreateFormFile("filename", filename); err != nil {
+		return "", err
+	}
+	headLen := head.Len()
+	// Closing the writer appends the final boundary
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	tail := append([]byte(nil), head.Bytes()[headLen:]...)
+	body := io.MultiReader(bytes.NewReader(head.Bytes()[:headLen]), file, bytes.NewReader(tail))
+
+	req, err := w.session.NewRequest(http.MethodPost, w.session.ApiUrl+"/nodes/"+node+"/storage/"+storage+"/upload", nil, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+	req.ContentLength = int64(headLen) + info.Size() + int64(len(tail))
+	resp, err := w.session.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return w.wait(ctx, resp)
+}
+
+// wait reads the task ID from the response of the request that started the
+// task and waits for the task. It returns the task ID.
+func (w *taskWaiter) wait(ctx context.Context, resp *http.Response) (string, error) {
+	var taskResponse struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&taskResponse); err != nil {
+		return "", fmt.Errorf("failed to parse task response: %s", err)
+	}
+	var upid string
+	if err := json.Unmarshal(taskResponse.Data, &upid); err != nil || upid == "" {
+		// Requests that complete immediately do not start a task
+		return "", nil
+	}
+	return upid, w.waitTask(ctx, upid)
+}
+
+// waitTask polls the status of the task until it stopped, printing new log
+// lines as they appear.
+func (w *taskWaiter) waitTask(ctx context.Context, upid string) error {
+	node, taskType, err := upidNodeAndType(upid)
+	if err != nil {
+		return err
+	}
+	taskPath := "/nodes/" + node + "/tasks/" + url.PathEscape(upid)
+	deadline := time.Now().Add(w.timeout)
+
+	var lines []string
+	for {
+		page, err := readTaskLogFrom(w.session, node, upid, len(lines))
+		if err != nil {
+			log.Printf("Failed to read log of task %s: %s", upid, err)
+		}
+		for _, line := range page {
+			w.ui.Message(taskType + ": " + line)
+		}
+		lines = append(lines, page...)
+
+		var status struct {
+			Status     string `json:"status"`
+			ExitStatus string `json:"exitstatus"`
+		}
+		if err := getData(w.session, taskPath+"/status", nil, &status); err != nil {
+			log.Printf("Failed to read status of task %s: %s", upid, err)
+		} else if status.Status == "stopped" {
+			// Pick up the lines logged since the last poll
+			if page, err := readTaskLogFrom(w.session, node, upid, len(lines)); err == nil {
+				for _, line := range page {
+					w.ui.Message(taskType + ": " + line)
+				}
+				lines = append(lines, page...)
+			}
+			if status.ExitStatus == "OK" || strings.HasPrefix(status.ExitStatus, "WARNINGS") {
+				return nil
+			}
+			return taskError(taskType, status.ExitStatus, lines)
+		}
+
+		if time.Now().After(deadline) {
+			w.stopTask(taskPath)
+			return taskError(taskType, fmt.Sprintf("timed out after %s", w.timeout), lines)
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			w.stopTask(taskPath)
+			return ctx.Err()
+		}
+	}
+}
+
+// stopTask asks Proxmox to abort a task that is no longer waited for.
+func (w *taskWaiter) stopTask(taskPath string) {
+	if _, err := w.session.Delete(taskPath, nil, nil); err != nil {
+		log.Printf("Failed to stop task %s: %s", taskPath, err)
+	}
+}
+
+// taskError describes a failed task, with the end of its log.
+func taskError(taskType string, exitStatus string, lines []string) error {
+	if len(lines) > taskLogTail {
+		lines = lines[len(lines)-taskLogTail:]
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("task %s failed: %s", taskType, exitStatus)
+	}
+	return fmt.Errorf("task %s failed: %s, last log lines:\n%s", taskType, exitStatus, strings.Join(lines, "\n"))
+}
+
+// upidNodeAndType returns the node and the type encoded in a task UPID, in
+// the form UPID:node:pid:pstart:starttime:type:id:user:
+func upidNodeAndType(upid string) (string, string, error) {
+	fields := strings.Split(upid, ":")
+	if len(fields) < 6 || fields[0] != "UPID" {
+		return "", "", fmt.Errorf("invalid task id %q", upid)
+	}
+	return fields[1], fields[5], nil
+}